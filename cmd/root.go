@@ -15,14 +15,21 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/nadig-google/cluster-director-mcp/pkg/config"
 	"github.com/nadig-google/cluster-director-mcp/pkg/install"
 	"github.com/nadig-google/cluster-director-mcp/pkg/tools"
+	"github.com/nadig-google/cluster-director-mcp/pkg/transport"
 	"github.com/spf13/cobra"
 )
 
@@ -48,6 +55,20 @@ var (
 		Short: "Install the Cluster Director MCP Server into your Gemini CLI settings.",
 		Run:   runInstallGeminiCLICmd,
 	}
+
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration of the Cluster Director MCP Server.",
+	}
+
+	configShowCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration and where each value came from.",
+		Args:  cobra.NoArgs,
+		Run:   runConfigShowCmd,
+	}
+
+	shutdownTimeout time.Duration
 )
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -62,41 +83,99 @@ func Execute() {
 func init() {
 	rootCmd.AddCommand(installCmd)
 	installCmd.AddCommand(installGeminiCLICmd)
+
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+
+	// Settings are persistent so that config show sees them too.
+	config.RegisterFlags(rootCmd.PersistentFlags())
+	rootCmd.Flags().DurationVar(&shutdownTimeout, "shutdown-timeout", transport.DefaultShutdownTimeout,
+		"How long in-flight requests are given to finish when the server is stopped")
 }
 
 func runRootCmd(cmd *cobra.Command, args []string) {
-	startMCPServer()
+	c, err := config.Load(version, cmd.Flags())
+	if err != nil {
+		exitf("Invalid configuration: %v", err)
+	}
+	if err := c.SetupLogging(); err != nil {
+		exitf("Could not set up logging: %v", err)
+	}
+	transportOpts := transport.Options{
+		Transport:       c.GetTransport(),
+		Address:         c.GetListenAddress(),
+		EndpointPath:    c.GetEndpointPath(),
+		BaseURL:         c.GetBaseURL(),
+		TLSCertFile:     c.GetTLSCertFile(),
+		TLSKeyFile:      c.GetTLSKeyFile(),
+		ShutdownTimeout: shutdownTimeout,
+	}
+	if err := transportOpts.Validate(); err != nil {
+		exitf("Invalid transport options: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	startMCPServer(ctx, c, transportOpts)
 }
 
-func startMCPServer() {
+func startMCPServer(ctx context.Context, c *config.Config, transportOpts transport.Options) {
 	s := server.NewMCPServer(
 		"Cluster Director Server",
 		version,
 		server.WithToolCapabilities(true),
 	)
 
-	c := config.New(version)
 	tools.Install(s, c)
 
-	log.Printf("Starting Cluster Director MCP Server")
-	if err := server.ServeStdio(s); err != nil {
-		log.Printf("Server error: %v\n", err)
+	slog.Info("Starting Cluster Director MCP Server", "version", version, "transport", transportOpts.Transport)
+	if err := transport.Serve(ctx, s, transportOpts); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("Server failed", "error", err)
+		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
+	}
+}
+
+// exitf logs the error that stops a command and exits. The error is printed
+// to stderr too, as the log may go to a file once logging is set up.
+func exitf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	slog.Error(msg)
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(1)
+}
+
+func runConfigShowCmd(cmd *cobra.Command, args []string) {
+	c, err := config.Load(version, cmd.Flags())
+	if err != nil {
+		exitf("Invalid configuration: %v", err)
+	}
+	path := c.Path()
+	if path == "" {
+		path = "none"
+	}
+	fmt.Printf("Configuration file: %s\n\n", path)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, v := range c.Values() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", v.Key, v.Value, v.Source)
 	}
+	w.Flush()
 }
 
 func runInstallGeminiCLICmd(cmd *cobra.Command, args []string) {
 	wd, err := os.Getwd()
 	if err != nil {
-		log.Fatalf("Failed to get current working directory: %v", err)
+		exitf("Failed to get current working directory: %v", err)
 	}
 
 	exePath, err := os.Executable()
 	if err != nil {
-		log.Fatalf("Failed to get executable path: %v", err)
+		exitf("Failed to get executable path: %v", err)
 	}
 
 	if err := install.GeminiCLIExtension(wd, version, exePath); err != nil {
-		log.Fatalf("Failed to install for gemini-cli: %v", err)
+		exitf("Failed to install for gemini-cli: %v", err)
 	}
 	fmt.Println("Successfully installed Cluster Director MCP server as a gemini-cli extension.")
 }
@@ -0,0 +1,189 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth provides the OAuth access tokens the server calls Google Cloud
+// APIs with. Tokens are refreshed transparently before they expire.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// Scopes are requested for every token. userinfo.email lets OS Login find
+// the account the token belongs to.
+var Scopes = []string{
+	"https://www.googleapis.com/auth/cloud-platform",
+	"https://www.googleapis.com/auth/userinfo.email",
+}
+
+// gcloudPath is the gcloud binary used when no Application Default
+// Credentials are found. It is looked up in PATH.
+var gcloudPath = "gcloud"
+
+// findDefaultCredentials looks up Application Default Credentials. Tests
+// replace it to run without them.
+var findDefaultCredentials = google.FindDefaultCredentials
+
+// NewTokenSource returns the token source of the server. The credentials are,
+// in order of preference:
+//
+//   - the service account key or other credentials file at credentialsFile,
+//     when it is not empty;
+//   - Application Default Credentials: the file named by
+//     GOOGLE_APPLICATION_CREDENTIALS, the one written by
+//     `gcloud auth application-default login`, or the metadata server when
+//     running on Google Cloud;
+//   - the account gcloud is logged in with, when gcloud is installed.
+func NewTokenSource(ctx context.Context, credentialsFile string) (oauth2.TokenSource, error) {
+	if credentialsFile != "" {
+		data, err := os.ReadFile(credentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read credentials file: %w", err)
+		}
+		creds, err := google.CredentialsFromJSON(ctx, data, Scopes...)
+		if err != nil {
+			return nil, fmt.Errorf("could not load credentials from %s: %w", credentialsFile, err)
+		}
+		slog.InfoContext(ctx, "Using credentials file", "path", credentialsFile)
+		return creds.TokenSource, nil
+	}
+
+	creds, adcErr := findDefaultCredentials(ctx, Scopes...)
+	if adcErr == nil {
+		slog.InfoContext(ctx, "Using Application Default Credentials")
+		return creds.TokenSource, nil
+	}
+	if GcloudInstalled() {
+		slog.InfoContext(ctx, "No Application Default Credentials, using gcloud", "error", adcErr)
+		return GcloudTokenSource(), nil
+	}
+	return nil, fmt.Errorf("no credentials found, run `gcloud auth application-default login` or set GOOGLE_APPLICATION_CREDENTIALS: %w", adcErr)
+}
+
+// GcloudTokenSource returns a token source backed by the account gcloud is
+// logged in with. A new token is requested from gcloud when the last one
+// expires.
+func GcloudTokenSource() oauth2.TokenSource {
+	return GcloudConfigurationTokenSource("")
+}
+
+// GcloudConfigurationTokenSource is GcloudTokenSource for the account of the
+// gcloud configuration called name, the active one if empty.
+func GcloudConfigurationTokenSource(name string) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, gcloudTokenSource{configuration: name})
+}
+
+// GcloudInstalled tells whether gcloud can be run for access tokens.
+func GcloudInstalled() bool {
+	_, err := exec.LookPath(gcloudPath)
+	return err == nil
+}
+
+type gcloudTokenSource struct {
+	configuration string
+}
+
+func (g gcloudTokenSource) Token() (*oauth2.Token, error) {
+	// Unlike print-access-token, config-helper reports when the token
+	// expires.
+	args := []string{"config", "config-helper", "--format=json"}
+	if g.configuration != "" {
+		args = append(args, "--configuration="+g.configuration)
+	}
+	out, err := exec.Command(gcloudPath, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not get an access token from gcloud: %w", err)
+	}
+	var helper struct {
+		Credential struct {
+			AccessToken string    `json:"access_token"`
+			TokenExpiry time.Time `json:"token_expiry"`
+		} `json:"credential"`
+	}
+	if err := json.Unmarshal(out, &helper); err != nil {
+		return nil, fmt.Errorf("could not parse gcloud credentials: %w", err)
+	}
+	if helper.Credential.AccessToken == "" {
+		return nil, fmt.Errorf("gcloud has no access token, run `gcloud auth login`")
+	}
+	slog.Debug("Retrieved access token from gcloud")
+	return &oauth2.Token{
+		AccessToken: helper.Credential.AccessToken,
+		TokenType:   "Bearer",
+		Expiry:      helper.Credential.TokenExpiry,
+	}, nil
+}
+
+// Unavailable returns a token source that always fails with err. It stands in
+// for the token source when no credentials are found, so that tools report
+// why instead of the server failing to start.
+func Unavailable(err error) oauth2.TokenSource {
+	return unavailable{err}
+}
+
+type unavailable struct{ err error }
+
+func (u unavailable) Token() (*oauth2.Token, error) { return nil, u.err }
+
+// SwitchableTokenSource is a token source whose tokens come from another one
+// that can be replaced while it is in use, e.g. when the user switches to
+// another account.
+type SwitchableTokenSource struct {
+	mu sync.RWMutex
+	ts oauth2.TokenSource
+}
+
+// NewSwitchableTokenSource returns a SwitchableTokenSource taking the tokens
+// of ts until Switch is called.
+func NewSwitchableTokenSource(ts oauth2.TokenSource) *SwitchableTokenSource {
+	return &SwitchableTokenSource{ts: ts}
+}
+
+func (s *SwitchableTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.RLock()
+	ts := s.ts
+	s.mu.RUnlock()
+	return ts.Token()
+}
+
+// Switch makes the next tokens come from ts.
+func (s *SwitchableTokenSource) Switch(ts oauth2.TokenSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ts = ts
+}
+
+// ErrNoAccessToken is returned by AccessToken when the credentials are
+// missing, expired or revoked.
+var ErrNoAccessToken = errors.New("could not get an access token")
+
+// AccessToken returns a valid access token from ts.
+func AccessToken(ts oauth2.TokenSource) (string, error) {
+	token, err := ts.Token()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNoAccessToken, err)
+	}
+	return token.AccessToken, nil
+}
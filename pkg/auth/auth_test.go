@@ -0,0 +1,117 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2/google"
+)
+
+// fakeGcloud installs a gcloud stand-in that prints a token expiring at
+// expiry, and returns the file counting how often it ran.
+func fakeGcloud(t *testing.T, expiry time.Time) string {
+	t.Helper()
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := fmt.Sprintf(`#!/bin/sh
+echo x >> %s
+n=$(wc -l < %s | tr -d ' ')
+echo '{"credential": {"access_token": "token-'$n'", "token_expiry": "%s"}}'
+`, calls, calls, expiry.UTC().Format(time.RFC3339))
+	path := filepath.Join(dir, "gcloud")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old := gcloudPath
+	gcloudPath = path
+	t.Cleanup(func() { gcloudPath = old })
+	return calls
+}
+
+func TestGcloudTokenSource(t *testing.T) {
+	fakeGcloud(t, time.Now().Add(time.Hour))
+	ts := GcloudTokenSource()
+	for i := 0; i < 2; i++ {
+		token, err := AccessToken(ts)
+		if err != nil || token != "token-1" {
+			t.Errorf("AccessToken() = %q, %v, want the cached token-1", token, err)
+		}
+	}
+
+	// Expired tokens are refreshed.
+	fakeGcloud(t, time.Now().Add(-time.Minute))
+	ts = GcloudTokenSource()
+	for _, want := range []string{"token-1", "token-2"} {
+		if token, err := AccessToken(ts); err != nil || token != want {
+			t.Errorf("AccessToken() = %q, %v, want %s", token, err, want)
+		}
+	}
+}
+
+func TestSwitchableTokenSource(t *testing.T) {
+	fakeGcloud(t, time.Now().Add(time.Hour))
+	ts := NewSwitchableTokenSource(Unavailable(errors.New("no credentials")))
+	if _, err := AccessToken(ts); err == nil {
+		t.Error("AccessToken() of unavailable credentials succeeded")
+	}
+	ts.Switch(GcloudConfigurationTokenSource("prod"))
+	if token, err := AccessToken(ts); err != nil || token != "token-1" {
+		t.Errorf("AccessToken() after Switch() = %q, %v, want the token of gcloud", token, err)
+	}
+}
+
+func TestNewTokenSource(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	creds := filepath.Join(dir, "creds.json")
+	os.WriteFile(creds, []byte(`{"type": "authorized_user", "client_id": "id", "client_secret": "secret", "refresh_token": "refresh"}`), 0o600)
+	if _, err := NewTokenSource(ctx, creds); err != nil {
+		t.Errorf("NewTokenSource(%s) failed: %v", creds, err)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`{"type": "unknown"}`), 0o600)
+	for _, file := range []string{bad, filepath.Join(dir, "missing.json")} {
+		if _, err := NewTokenSource(ctx, file); err == nil {
+			t.Errorf("NewTokenSource(%s) succeeded", file)
+		}
+	}
+
+	// Without Application Default Credentials gcloud is used.
+	findDefaultCredentials = func(ctx context.Context, scopes ...string) (*google.Credentials, error) {
+		return nil, errors.New("no ADC")
+	}
+	defer func() { findDefaultCredentials = google.FindDefaultCredentials }()
+	fakeGcloud(t, time.Now().Add(time.Hour))
+	ts, err := NewTokenSource(ctx, "")
+	if err != nil {
+		t.Fatalf("NewTokenSource() without ADC failed: %v", err)
+	}
+	if token, err := AccessToken(ts); err != nil || !strings.HasPrefix(token, "token-") {
+		t.Errorf("AccessToken() = %q, %v, want a gcloud token", token, err)
+	}
+
+	errNoCreds := errors.New("no credentials")
+	if _, err := AccessToken(Unavailable(errNoCreds)); !errors.Is(err, errNoCreds) {
+		t.Errorf("AccessToken(Unavailable) = %v, want %v", err, errNoCreds)
+	}
+}
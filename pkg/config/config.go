@@ -15,19 +15,62 @@
 package config
 
 import (
-	"fmt"
-	"log"
-	"os/exec"
 	"strings"
+	"sync"
 
 	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
 )
 
+// DefaultAPIEndpoint is the root of the Cluster Director REST API, and
+// DefaultAPIVersion the version of it the server talks to.
+const (
+	DefaultAPIEndpoint = "https://hypercomputecluster.googleapis.com"
+	DefaultAPIVersion  = "v1alpha"
+)
+
 type Config struct {
-	userAgent        string
-	defaultProjectID string
-	defaultZone      string
-	defaultRegion    string
+	userAgent string
+
+	// mu guards the settings a gcloud configuration switches while the
+	// server runs.
+	mu                  sync.RWMutex
+	defaultProjectID    string
+	defaultZone         string
+	defaultRegion       string
+	account             string
+	gcloudConfiguration string
+
+	apiEndpoint     string
+	apiVersion      string
+	logDir          string
+	logLevel        string
+	logFormat       string
+	logOutput       string
+	transport       string
+	listenAddress   string
+	endpointPath    string
+	baseURL         string
+	tlsCertFile     string
+	tlsKeyFile      string
+	slurmBackend    string
+	credentialsFile string
+	// projects, folder and projectLabels select the projects list_clusters
+	// covers instead of the default project.
+	projects      []string
+	folder        string
+	projectLabels []string
+	// toolAllowlist holds the names of the tools to register, or nothing to
+	// register them all.
+	toolAllowlist []string
+
+	// sources records where each setting was read from, by key.
+	sources map[string]string
+	// path is the configuration file that was read.
+	path string
+	// usedGcloud is the gcloud configuration Load took defaults from, or
+	// gcloudErr why it took none.
+	usedGcloud string
+	gcloudErr  error
 }
 
 func (c *Config) UserAgent() string {
@@ -35,69 +78,290 @@ func (c *Config) UserAgent() string {
 }
 
 func (c *Config) GetDefaultProjectID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.defaultProjectID
 }
 
 func (c *Config) SetDefaultProjectID(p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.defaultProjectID = p
 }
 
 func (c *Config) GetDefaultZone() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.defaultZone
 }
 
 func (c *Config) SetDefaultZone(p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.defaultZone = p
 }
 
 func (c *Config) GetDefaultRegion() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.defaultRegion
 }
 
 func (c *Config) SetDefaultRegion(p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.defaultRegion = p
 }
 
-func New(version string) *Config {
-	return &Config{
-		userAgent:        "cluster-director-mcp/" + version,
-		defaultProjectID: getDefaultProjectID(),
+// GetAccount returns the account of the gcloud configuration in use. It is
+// informational, the credentials of the server do not change with it.
+func (c *Config) GetAccount() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.account
+}
+
+// GetGcloudConfiguration returns the name of the gcloud configuration the
+// project, region and zone default to, or "" for the active one.
+func (c *Config) GetGcloudConfiguration() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.gcloudConfiguration
+}
+
+func (c *Config) SetGcloudConfiguration(p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gcloudConfiguration = p
+}
+
+// UseGcloudConfiguration switches the project, region, zone and account to
+// those of the gcloud configuration called name, or the active one when name
+// is empty.
+func (c *Config) UseGcloudConfiguration(name string) (*GcloudConfiguration, error) {
+	gc, err := ReadGcloudConfiguration(name)
+	if err != nil {
+		return nil, err
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gcloudConfiguration = gc.Name
+	c.defaultProjectID, c.defaultRegion, c.defaultZone, c.account = gc.Project, gc.Region, gc.Zone, gc.Account
+	if c.sources != nil {
+		for _, key := range []string{"gcloud_configuration", "project", "region", "zone"} {
+			c.sources[key] = SourceGcloud
+		}
+	}
+	return gc, nil
 }
 
-func getDefaultProjectID() string {
-	/*
-			ctx := context.Background()
-			creds, err := google.FindDefaultCredentials:qcreds(ctx)
-			if err != nil {
-				genericCore.WriteToLog(fmt.Sprintf("Failed to find default credentials: %v", err))
-				log.Fatalf("Failed to find default credentials: %v", err)
-			}
+// GetAPIEndpoint returns the versioned root of the Cluster Director REST API,
+// e.g. https://hypercomputecluster.googleapis.com/v1alpha. Endpoints that
+// already end with the version are returned as they are.
+func (c *Config) GetAPIEndpoint() string {
+	endpoint := strings.TrimSuffix(c.apiEndpoint, "/")
+	if c.apiVersion == "" || strings.HasSuffix(endpoint, "/"+c.apiVersion) {
+		return endpoint
+	}
+	return endpoint + "/" + c.apiVersion
+}
 
+// SetAPIEndpoint sets the root of the API, without the version.
+func (c *Config) SetAPIEndpoint(p string) {
+	c.apiEndpoint = p
+}
 
-		projectID, err := gcp.DefaultProjectID(nil) // nil for default credentials
+func (c *Config) GetAPIVersion() string {
+	return c.apiVersion
+}
 
-		// The ProjectID field will be populated if ADC can find it.
-		if err == nil {
-			genericCore.WriteToLog("Could not find a default project ID. ")
-			genericCore.WriteToLog("Set the GOOGLE_CLOUD_PROJECT environment variable or run 'gcloud config set project YOUR_PROJECT_ID'.")
-			fmt.Println("Could not find a default project ID.")
-			fmt.Println("Set the GOOGLE_CLOUD_PROJECT environment variable or run 'gcloud config set project YOUR_PROJECT_ID'.")
-		} else {
-			genericCore.WriteToLog("Default Project ID: " + string(projectID))
-			fmt.Printf("Default Project ID: %s\n", string(projectID))
-		}
+func (c *Config) SetAPIVersion(p string) {
+	c.apiVersion = p
+}
 
-		return string(projectID)
-	*/
+// GetLogDir returns the directory the log files are written to.
+func (c *Config) GetLogDir() string {
+	if c.logDir == "" {
+		return genericCore.DefaultLogDir
+	}
+	return c.logDir
+}
 
-	out, err := exec.Command("gcloud", "config", "get", "core/project").Output()
-	if err != nil {
-		genericCore.WriteToLog(fmt.Sprintf("Failed to get default project: %v", err))
-		return ""
+func (c *Config) SetLogDir(p string) {
+	c.logDir = p
+}
+
+// GetLogLevel returns the lowest level of the messages logged.
+func (c *Config) GetLogLevel() string {
+	if c.logLevel == "" {
+		return "info"
+	}
+	return c.logLevel
+}
+
+func (c *Config) SetLogLevel(p string) {
+	c.logLevel = p
+}
+
+// GetLogFormat returns the format of the log messages: "text" or "json".
+func (c *Config) GetLogFormat() string {
+	if c.logFormat == "" {
+		return genericCore.LogFormatText
+	}
+	return c.logFormat
+}
+
+func (c *Config) SetLogFormat(p string) {
+	c.logFormat = p
+}
+
+// GetLogOutput returns where log messages are written: "file", "stderr" or
+// "stdout".
+func (c *Config) GetLogOutput() string {
+	if c.logOutput == "" {
+		return genericCore.LogToFile
+	}
+	return c.logOutput
+}
+
+func (c *Config) SetLogOutput(p string) {
+	c.logOutput = p
+}
+
+// GetTransport returns the transport MCP clients are served over.
+func (c *Config) GetTransport() string {
+	return c.transport
+}
+
+func (c *Config) SetTransport(p string) {
+	c.transport = p
+}
+
+// GetListenAddress returns the host:port the HTTP transports listen on.
+func (c *Config) GetListenAddress() string {
+	return c.listenAddress
+}
+
+func (c *Config) SetListenAddress(p string) {
+	c.listenAddress = p
+}
+
+func (c *Config) GetEndpointPath() string {
+	return c.endpointPath
+}
+
+func (c *Config) SetEndpointPath(p string) {
+	c.endpointPath = p
+}
+
+func (c *Config) GetBaseURL() string {
+	return c.baseURL
+}
+
+func (c *Config) SetBaseURL(p string) {
+	c.baseURL = p
+}
+
+func (c *Config) GetTLSCertFile() string {
+	return c.tlsCertFile
+}
+
+func (c *Config) SetTLSCertFile(p string) {
+	c.tlsCertFile = p
+}
+
+func (c *Config) GetTLSKeyFile() string {
+	return c.tlsKeyFile
+}
+
+func (c *Config) SetTLSKeyFile(p string) {
+	c.tlsKeyFile = p
+}
+
+// GetSlurmBackend returns how Slurm is queried by default: "auto", "rest" or
+// "ssh".
+func (c *Config) GetSlurmBackend() string {
+	if c.slurmBackend == "" {
+		return "auto"
+	}
+	return c.slurmBackend
+}
+
+func (c *Config) SetSlurmBackend(p string) {
+	c.slurmBackend = p
+}
+
+// GetCredentialsFile returns the credentials file the server authenticates
+// with, or "" to use Application Default Credentials.
+func (c *Config) GetCredentialsFile() string {
+	return c.credentialsFile
+}
+
+func (c *Config) SetCredentialsFile(p string) {
+	c.credentialsFile = p
+}
+
+// GetProjects returns the projects list_clusters covers.
+func (c *Config) GetProjects() []string {
+	return c.projects
+}
+
+func (c *Config) SetProjects(p []string) {
+	c.projects = p
+}
+
+// GetFolder returns the folder whose projects list_clusters covers.
+func (c *Config) GetFolder() string {
+	return c.folder
+}
+
+func (c *Config) SetFolder(p string) {
+	c.folder = p
+}
+
+// GetProjectLabels returns the key=value labels that select the projects
+// list_clusters covers.
+func (c *Config) GetProjectLabels() []string {
+	return c.projectLabels
+}
+
+func (c *Config) SetProjectLabels(p []string) {
+	c.projectLabels = p
+}
+
+// GetToolAllowlist returns the names of the tools to register. It is empty
+// when every tool is registered.
+func (c *Config) GetToolAllowlist() []string {
+	return c.toolAllowlist
+}
+
+func (c *Config) SetToolAllowlist(p []string) {
+	c.toolAllowlist = p
+}
+
+// IsToolEnabled reports whether the tool called name is registered.
+func (c *Config) IsToolEnabled(name string) bool {
+	if len(c.toolAllowlist) == 0 {
+		return true
+	}
+	for _, t := range c.toolAllowlist {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// New returns a Config holding the default of every setting. Use Load to
+// read the configuration file, environment and flags.
+func New(version string) *Config {
+	c := &Config{
+		userAgent: "cluster-director-mcp/" + version,
+		sources:   make(map[string]string),
+	}
+	for _, s := range settings {
+		s.set(c, s.def)
+		c.sources[s.key] = SourceDefault
 	}
-	projectID := strings.TrimSpace(string(out))
-	log.Printf("Using default project ID: %s", projectID)
-	genericCore.WriteToLog(fmt.Sprintf("Using default project ID: %s", projectID))
-	return projectID
+	return c
 }
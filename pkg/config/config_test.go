@@ -0,0 +1,212 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// writeConfig writes a configuration file and points the environment at it.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvPrefix+"CONFIG", path)
+	return path
+}
+
+// fakeGcloudConfig makes gcloud configurations dev, the active one, and prod.
+func fakeGcloudConfig(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("CLOUDSDK_CONFIG", dir)
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "")
+	os.MkdirAll(filepath.Join(dir, "configurations"), 0o755)
+	for file, content := range map[string]string{
+		"active_config": "dev\n",
+		"configurations/config_dev": `[core]
+account = dev@example.com
+project = dev-project
+# disable_usage_reporting = True
+
+[compute]
+region = us-central1
+zone = us-central1-a
+`,
+		"configurations/config_prod": `[core]
+account = prod@example.com
+project = prod-project
+`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGcloudConfiguration(t *testing.T) {
+	fakeGcloudConfig(t)
+	gc, err := ReadGcloudConfiguration("")
+	if err != nil {
+		t.Fatalf("ReadGcloudConfiguration() failed: %v", err)
+	}
+	want := GcloudConfiguration{Name: "dev", Project: "dev-project", Region: "us-central1", Zone: "us-central1-a", Account: "dev@example.com"}
+	if *gc != want {
+		t.Errorf("ReadGcloudConfiguration() = %+v, want %+v", *gc, want)
+	}
+	for _, name := range []string{"missing", "../prod"} {
+		if _, err := ReadGcloudConfiguration(name); err == nil {
+			t.Errorf("ReadGcloudConfiguration(%q) succeeded", name)
+		}
+	}
+	list, err := ListGcloudConfigurations()
+	if err != nil || len(list) != 2 || list[1].Project != "prod-project" {
+		t.Errorf("ListGcloudConfigurations() = %+v, %v, want dev and prod", list, err)
+	}
+
+	// The gcloud configuration only fills what no other layer sets.
+	t.Setenv(EnvPrefix+"CONFIG", filepath.Join(t.TempDir(), "none.json"))
+	os.WriteFile(os.Getenv(EnvPrefix+"CONFIG"), []byte(`{"zone": "file-zone"}`), 0o600)
+	c, err := Load("test", nil)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if c.GetDefaultProjectID() != "dev-project" || c.GetDefaultZone() != "file-zone" || c.GetAccount() != "dev@example.com" {
+		t.Errorf("Load() = project %q, zone %q, account %q, want the gcloud project and account and the file zone",
+			c.GetDefaultProjectID(), c.GetDefaultZone(), c.GetAccount())
+	}
+
+	t.Setenv(EnvPrefix+"GCLOUD_CONFIGURATION", "prod")
+	if c, err := Load("test", nil); err != nil || c.GetDefaultProjectID() != "prod-project" {
+		t.Errorf("Load() with the prod configuration = %v, want prod-project", err)
+	}
+	t.Setenv(EnvPrefix+"GCLOUD_CONFIGURATION", "missing")
+	if _, err := Load("test", nil); err == nil {
+		t.Errorf("Load() with a missing gcloud configuration succeeded")
+	}
+
+	gc, err = c.UseGcloudConfiguration("prod")
+	if err != nil || gc.Project != "prod-project" {
+		t.Fatalf("UseGcloudConfiguration(prod) = %+v, %v", gc, err)
+	}
+	if c.GetDefaultProjectID() != "prod-project" || c.GetDefaultRegion() != "" || c.GetGcloudConfiguration() != "prod" {
+		t.Errorf("UseGcloudConfiguration(prod) left project %q, region %q, configuration %q",
+			c.GetDefaultProjectID(), c.GetDefaultRegion(), c.GetGcloudConfiguration())
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fakeGcloudConfig(t)
+	logDir := t.TempDir()
+	path := writeConfig(t, `{
+		"project": "file-project",
+		"region": "file-region",
+		"zone": "file-zone",
+		"api_endpoint": "http://localhost:9000/",
+		"log_dir": "`+logDir+`",
+		"tools": ["list_clusters", "get_cluster"]
+	}`)
+	t.Setenv(EnvPrefix+"REGION", "env-region")
+	t.Setenv(EnvPrefix+"ZONE", "env-zone")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	RegisterFlags(flags)
+	if err := flags.Parse([]string{"--zone=flag-zone", "--transport=sse"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load("test", flags)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	// Only the server sets up logging, config show must not write logs.
+	if entries, _ := os.ReadDir(logDir); len(entries) > 0 {
+		t.Errorf("Load() wrote %d log file(s) to %s", len(entries), logDir)
+	}
+	if c.Path() != path {
+		t.Errorf("Path() = %q, want %q", c.Path(), path)
+	}
+	sources := make(map[string]Value)
+	for _, v := range c.Values() {
+		sources[v.Key] = v
+	}
+	for _, want := range []Value{
+		{"project", "file-project", SourceFile},
+		{"region", "env-region", SourceEnv},
+		{"zone", "flag-zone", SourceFlag},
+		{"transport", "sse", SourceFlag},
+		{"api_version", DefaultAPIVersion, SourceDefault},
+		{"tools", "list_clusters,get_cluster", SourceFile},
+	} {
+		if got := sources[want.Key]; got != want {
+			t.Errorf("setting %s = %+v, want %+v", want.Key, got, want)
+		}
+	}
+	if got, want := c.GetAPIEndpoint(), "http://localhost:9000/v1alpha"; got != want {
+		t.Errorf("GetAPIEndpoint() = %q, want %q", got, want)
+	}
+	if !c.IsToolEnabled("get_cluster") || c.IsToolEnabled("delete_cluster") {
+		t.Errorf("IsToolEnabled() does not follow the allowlist %v", c.GetToolAllowlist())
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	fakeGcloudConfig(t)
+	for _, content := range []string{
+		`{"project": `,
+		`{"unknown": "value"}`,
+		`{"project": ["a", "b"]}`,
+	} {
+		writeConfig(t, content)
+		if _, err := Load("test", nil); err == nil {
+			t.Errorf("Load() of configuration file %s succeeded", content)
+		}
+	}
+
+	for _, content := range []string{
+		`{"log_level": "verbose"}`,
+		`{"log_format": "xml"}`,
+		`{"log_output": "syslog"}`,
+		`{"log_output": "stdout", "transport": "stdio"}`,
+	} {
+		writeConfig(t, content)
+		if _, err := Load("test", nil); err == nil {
+			t.Errorf("Load() of log settings %s succeeded", content)
+		}
+	}
+
+	t.Setenv(EnvPrefix+"CONFIG", filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := Load("test", nil); err == nil || !strings.Contains(err.Error(), "missing.json") {
+		t.Errorf("Load() of a missing configuration file = %v, want an error", err)
+	}
+}
+
+func TestAPIEndpoint(t *testing.T) {
+	c := New("test")
+	if got, want := c.GetAPIEndpoint(), "https://hypercomputecluster.googleapis.com/v1alpha"; got != want {
+		t.Errorf("GetAPIEndpoint() = %q, want %q", got, want)
+	}
+	// Endpoints that already hold the version are kept.
+	c.SetAPIEndpoint("http://localhost:8080/v1alpha")
+	if got, want := c.GetAPIEndpoint(), "http://localhost:8080/v1alpha"; got != want {
+		t.Errorf("GetAPIEndpoint() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// GcloudConfiguration holds the properties of a named gcloud configuration
+// the server uses, as set by `gcloud config set`.
+type GcloudConfiguration struct {
+	Name    string `json:"name"`
+	Project string `json:"project,omitempty"`
+	Region  string `json:"region,omitempty"`
+	Zone    string `json:"zone,omitempty"`
+	Account string `json:"account,omitempty"`
+}
+
+// gcloudConfigurationName is what gcloud accepts as a configuration name.
+var gcloudConfigurationName = regexp.MustCompile(`^[a-z][-a-z0-9]*$`)
+
+// gcloudConfigDir returns the directory gcloud keeps its configurations in.
+func gcloudConfigDir() (string, error) {
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return dir, nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("APPDATA"); dir != "" {
+			return filepath.Join(dir, "gcloud"), nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find the gcloud configuration directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "gcloud"), nil
+}
+
+// ActiveGcloudConfiguration returns the name of the gcloud configuration
+// gcloud itself would use.
+func ActiveGcloudConfiguration() string {
+	if name := os.Getenv("CLOUDSDK_ACTIVE_CONFIG_NAME"); name != "" {
+		return name
+	}
+	if dir, err := gcloudConfigDir(); err == nil {
+		if data, err := os.ReadFile(filepath.Join(dir, "active_config")); err == nil {
+			if name := strings.TrimSpace(string(data)); name != "" {
+				return name
+			}
+		}
+	}
+	return "default"
+}
+
+// ReadGcloudConfiguration reads the gcloud configuration called name, or the
+// active one when name is empty, without running gcloud.
+func ReadGcloudConfiguration(name string) (*GcloudConfiguration, error) {
+	if name == "" {
+		name = ActiveGcloudConfiguration()
+	}
+	if !gcloudConfigurationName.MatchString(name) {
+		return nil, fmt.Errorf("invalid gcloud configuration name %q", name)
+	}
+	dir, err := gcloudConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, "configurations", "config_"+name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("gcloud configuration %q does not exist", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read gcloud configuration %q: %w", name, err)
+	}
+	defer f.Close()
+
+	properties, err := parseINI(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse gcloud configuration %q: %w", name, err)
+	}
+	return &GcloudConfiguration{
+		Name:    name,
+		Project: properties["core/project"],
+		Region:  properties["compute/region"],
+		Zone:    properties["compute/zone"],
+		Account: properties["core/account"],
+	}, nil
+}
+
+// ListGcloudConfigurations returns the gcloud configurations, sorted by name.
+// Configurations that cannot be read are left out.
+func ListGcloudConfigurations() ([]GcloudConfiguration, error) {
+	dir, err := gcloudConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "configurations", "config_*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	var configurations []GcloudConfiguration
+	for _, file := range files {
+		gc, err := ReadGcloudConfiguration(strings.TrimPrefix(filepath.Base(file), "config_"))
+		if err != nil {
+			continue
+		}
+		configurations = append(configurations, *gc)
+	}
+	return configurations, nil
+}
+
+// parseINI returns the properties in an INI file as section/name.
+func parseINI(f *os.File) (map[string]string, error) {
+	properties := make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+		default:
+			name, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("malformed line %q", line)
+			}
+			properties[section+"/"+strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return properties, scanner.Err()
+}
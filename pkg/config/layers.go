@@ -0,0 +1,445 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
+	"github.com/nadig-google/cluster-director-mcp/pkg/transport"
+)
+
+// Where the value of a setting came from, from lowest to highest precedence.
+// The project, region and zone fall back to those of a gcloud configuration
+// when no other layer sets them.
+const (
+	SourceDefault = "default"
+	SourceGcloud  = "gcloud"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+const (
+	// EnvPrefix starts the name of the environment variable of every
+	// setting, e.g. CLUSTER_DIRECTOR_MCP_PROJECT.
+	EnvPrefix = "CLUSTER_DIRECTOR_MCP_"
+	// ConfigFlag names the flag, and EnvPrefix+"CONFIG" the environment
+	// variable, that point at a configuration file other than the default.
+	ConfigFlag = "config"
+)
+
+// setting is a value that can be set in the configuration file, the
+// environment and on the command line.
+type setting struct {
+	// key names the setting in the configuration file and, upper-cased after
+	// EnvPrefix, in the environment.
+	key   string
+	flag  string
+	usage string
+	def   string
+	// list settings hold comma separated values.
+	list bool
+	get  func(*Config) string
+	set  func(*Config, string)
+}
+
+var settings = []setting{
+	{
+		key: "project", flag: "project",
+		usage: "Default Google Cloud project. Defaults to the core/project property of the gcloud configuration",
+		get:   (*Config).GetDefaultProjectID, set: (*Config).SetDefaultProjectID,
+	},
+	{
+		key: "region", flag: "region",
+		usage: "Default region of clusters. Defaults to the compute/region property of the gcloud configuration",
+		get:   (*Config).GetDefaultRegion, set: (*Config).SetDefaultRegion,
+	},
+	{
+		key: "zone", flag: "zone",
+		usage: "Default zone of cluster nodes. Defaults to the compute/zone property of the gcloud configuration",
+		get:   (*Config).GetDefaultZone, set: (*Config).SetDefaultZone,
+	},
+	{
+		key: "projects", flag: "projects", list: true,
+		usage: "Comma separated projects list_clusters covers. Defaults to the default project",
+		get:   func(c *Config) string { return strings.Join(c.GetProjects(), ",") },
+		set:   func(c *Config, v string) { c.SetProjects(splitList(v)) },
+	},
+	{
+		key: "folder", flag: "folder",
+		usage: "Folder ID whose projects list_clusters covers",
+		get:   (*Config).GetFolder, set: (*Config).SetFolder,
+	},
+	{
+		key: "project_labels", flag: "project-labels", list: true,
+		usage: "Comma separated key=value labels selecting the projects list_clusters covers",
+		get:   func(c *Config) string { return strings.Join(c.GetProjectLabels(), ",") },
+		set:   func(c *Config, v string) { c.SetProjectLabels(splitList(v)) },
+	},
+	{
+		key: "gcloud_configuration", flag: "gcloud-configuration",
+		usage: "gcloud configuration the project, region and zone default to. Defaults to the active one",
+		get:   (*Config).GetGcloudConfiguration, set: (*Config).SetGcloudConfiguration,
+	},
+	{
+		key: "api_endpoint", flag: "api-endpoint", def: DefaultAPIEndpoint,
+		usage: "Root URL of the Cluster Director API, e.g. a local stand-in server",
+		get:   func(c *Config) string { return c.apiEndpoint }, set: (*Config).SetAPIEndpoint,
+	},
+	{
+		key: "api_version", flag: "api-version", def: DefaultAPIVersion,
+		usage: "Version of the Cluster Director API",
+		get:   (*Config).GetAPIVersion, set: (*Config).SetAPIVersion,
+	},
+	{
+		key: "log_dir", flag: "log-dir", def: genericCore.DefaultLogDir,
+		usage: "Directory log files are written to",
+		get:   (*Config).GetLogDir, set: (*Config).SetLogDir,
+	},
+	{
+		key: "log_level", flag: "log-level", def: "info",
+		usage: "Lowest level of the messages logged: debug, info, warn or error",
+		get:   (*Config).GetLogLevel, set: (*Config).SetLogLevel,
+	},
+	{
+		key: "log_format", flag: "log-format", def: genericCore.LogFormatText,
+		usage: "Format of the log messages: text or json",
+		get:   (*Config).GetLogFormat, set: (*Config).SetLogFormat,
+	},
+	{
+		key: "log_output", flag: "log-output", def: genericCore.LogToFile,
+		usage: "Where log messages are written: file (in log_dir), stderr, or stdout (not with the stdio transport)",
+		get:   (*Config).GetLogOutput, set: (*Config).SetLogOutput,
+	},
+	{
+		key: "transport", flag: "transport", def: transport.Stdio,
+		usage: "Transport used to serve MCP clients, one of: " + strings.Join(transport.Names(), ", "),
+		get:   (*Config).GetTransport, set: (*Config).SetTransport,
+	},
+	{
+		key: "listen", flag: "listen", def: transport.DefaultAddress,
+		usage: "Address (host:port) the sse and streamable-http transports listen on",
+		get:   (*Config).GetListenAddress, set: (*Config).SetListenAddress,
+	},
+	{
+		key: "endpoint_path", flag: "endpoint-path", def: transport.DefaultEndpointPath,
+		usage: "HTTP path the sse and streamable-http transports are served under",
+		get:   (*Config).GetEndpointPath, set: (*Config).SetEndpointPath,
+	},
+	{
+		key: "base_url", flag: "base-url",
+		usage: "Externally visible URL of the server, advertised to sse clients. Defaults to the listen address",
+		get:   (*Config).GetBaseURL, set: (*Config).SetBaseURL,
+	},
+	{
+		key: "tls_cert", flag: "tls-cert",
+		usage: "PEM certificate file. Enables HTTPS together with --tls-key",
+		get:   (*Config).GetTLSCertFile, set: (*Config).SetTLSCertFile,
+	},
+	{
+		key: "tls_key", flag: "tls-key",
+		usage: "PEM private key file. Enables HTTPS together with --tls-cert",
+		get:   (*Config).GetTLSKeyFile, set: (*Config).SetTLSKeyFile,
+	},
+	{
+		key: "slurm_backend", flag: "slurm-backend", def: "auto",
+		usage: "How Slurm is queried by default: rest (Cluster Director CallSlurm API), ssh (login node), or auto (rest, falling back to ssh)",
+		get:   (*Config).GetSlurmBackend, set: (*Config).SetSlurmBackend,
+	},
+	{
+		key: "credentials_file", flag: "credentials-file",
+		usage: "Service account key or other Google credentials file. Defaults to Application Default Credentials, then to the gcloud account",
+		get:   (*Config).GetCredentialsFile, set: (*Config).SetCredentialsFile,
+	},
+	{
+		key: "tools", flag: "tools", list: true,
+		usage: "Comma separated names of the tools to register. Defaults to all of them",
+		get:   func(c *Config) string { return strings.Join(c.GetToolAllowlist(), ",") },
+		set:   func(c *Config, v string) { c.SetToolAllowlist(splitList(v)) },
+	},
+}
+
+// splitList splits a comma separated list, dropping empty entries.
+func splitList(v string) []string {
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func (s setting) env() string {
+	return EnvPrefix + strings.ToUpper(s.key)
+}
+
+// RegisterFlags adds a flag for every setting, and ConfigFlag, to flags.
+func RegisterFlags(flags *pflag.FlagSet) {
+	flags.String(ConfigFlag, "", "Configuration file. Defaults to "+displayPath(DefaultPath()))
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env())
+		if s.list {
+			flags.StringSlice(s.flag, splitList(s.def), usage)
+		} else {
+			flags.String(s.flag, s.def, usage)
+		}
+	}
+}
+
+// DefaultPath returns the configuration file read when none is given, under
+// the user configuration directory. It is "" when that directory is unknown.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "cluster-director-mcp", "config.json")
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "none"
+	}
+	return path
+}
+
+// Load returns the configuration of the server. Every setting is taken from,
+// in order of precedence, the flags in flags that were set, the environment,
+// the configuration file and its default. flags may be nil.
+//
+// The configuration file is a JSON object keyed by setting name:
+//
+//	{"project": "my-project", "region": "us-central1", "tools": ["list_clusters"]}
+//
+// A missing default configuration file is not an error, a missing file named
+// with ConfigFlag or its environment variable is. The log settings are
+// checked but only take effect with SetupLogging.
+func Load(version string, flags *pflag.FlagSet) (*Config, error) {
+	c := New(version)
+
+	path, explicit := DefaultPath(), false
+	if v, ok := os.LookupEnv(EnvPrefix + "CONFIG"); ok && v != "" {
+		path, explicit = v, true
+	}
+	if f := lookupFlag(flags, ConfigFlag); f != nil && f.Changed {
+		path, explicit = f.Value.String(), true
+	}
+	file, err := readFile(path, explicit)
+	if err != nil {
+		return nil, err
+	}
+	c.path = path
+	if file == nil {
+		c.path = ""
+	}
+
+	for _, s := range settings {
+		if v, ok := file[s.key]; ok {
+			s.set(c, v)
+			c.sources[s.key] = SourceFile
+		}
+		if v, ok := os.LookupEnv(s.env()); ok {
+			s.set(c, v)
+			c.sources[s.key] = SourceEnv
+		}
+		if f := lookupFlag(flags, s.flag); f != nil && f.Changed {
+			v := f.Value.String()
+			if sv, ok := f.Value.(pflag.SliceValue); ok {
+				v = strings.Join(sv.GetSlice(), ",")
+			}
+			s.set(c, v)
+			c.sources[s.key] = SourceFlag
+		}
+	}
+
+	if err := c.logOptions().Validate(); err != nil {
+		return nil, err
+	}
+	if c.GetLogOutput() == genericCore.LogToStdout && c.stdio() {
+		return nil, fmt.Errorf("log_output %s cannot be used with the %s transport, whose messages go to stdout", genericCore.LogToStdout, transport.Stdio)
+	}
+	if err := c.useGcloudDefaults(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) stdio() bool {
+	return c.GetTransport() == "" || c.GetTransport() == transport.Stdio
+}
+
+func (c *Config) logOptions() genericCore.LogOptions {
+	return genericCore.LogOptions{
+		Level:  c.GetLogLevel(),
+		Format: c.GetLogFormat(),
+		Output: c.GetLogOutput(),
+		Dir:    c.GetLogDir(),
+	}
+}
+
+// SetupLogging makes the default logger write as the log settings say from
+// here on, and logs where the configuration came from. Load leaves logging
+// alone, so that commands which only inspect the configuration write no
+// logs.
+func (c *Config) SetupLogging() error {
+	if err := genericCore.SetupLogging(c.logOptions()); err != nil {
+		return err
+	}
+	if c.usedGcloud == "" {
+		slog.Info("Not using a gcloud configuration", "error", c.gcloudErr)
+	} else {
+		slog.Info("Using gcloud configuration", "configuration", c.usedGcloud)
+	}
+	if p := c.GetDefaultProjectID(); p != "" {
+		slog.Info("Using default project", "project", p)
+	}
+	return nil
+}
+
+// useGcloudDefaults fills the project, region and zone no other layer set
+// from the gcloud configuration. A missing active configuration is not an
+// error, a missing named one is.
+func (c *Config) useGcloudDefaults() error {
+	name := c.GetGcloudConfiguration()
+	gc, err := ReadGcloudConfiguration(name)
+	if err != nil {
+		if name != "" {
+			return err
+		}
+		c.gcloudErr = err
+		return nil
+	}
+	c.usedGcloud = gc.Name
+	for _, d := range []struct {
+		key   string
+		value string
+		set   func(*Config, string)
+	}{
+		{"project", gc.Project, (*Config).SetDefaultProjectID},
+		{"region", gc.Region, (*Config).SetDefaultRegion},
+		{"zone", gc.Zone, (*Config).SetDefaultZone},
+	} {
+		if d.value != "" && c.sources[d.key] == SourceDefault {
+			d.set(c, d.value)
+			c.sources[d.key] = SourceGcloud
+		}
+	}
+	c.mu.Lock()
+	c.account = gc.Account
+	c.mu.Unlock()
+	return nil
+}
+
+func lookupFlag(flags *pflag.FlagSet, name string) *pflag.Flag {
+	if flags == nil {
+		return nil
+	}
+	return flags.Lookup(name)
+}
+
+// readFile reads the settings in the configuration file at path. It returns
+// nil when there is no file, unless explicit is set.
+func readFile(path string, explicit bool) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read configuration file: %w", err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("could not parse configuration file %s: %w", path, err)
+	}
+
+	known := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		known[s.key] = s
+	}
+	file := make(map[string]string, len(raw))
+	for key, value := range raw {
+		s, ok := known[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown setting %q in configuration file %s", key, path)
+		}
+		var v string
+		if err := json.Unmarshal(value, &v); err == nil {
+			file[key] = v
+			continue
+		}
+		var list []string
+		if err := json.Unmarshal(value, &list); err == nil && s.list {
+			file[key] = strings.Join(list, ",")
+			continue
+		}
+		if s.list {
+			return nil, fmt.Errorf("setting %q in configuration file %s must be a string or a list of strings", key, path)
+		}
+		return nil, fmt.Errorf("setting %q in configuration file %s must be a string", key, path)
+	}
+	return file, nil
+}
+
+// Value is the effective value of a setting and where it came from.
+type Value struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Values returns the effective value of every setting, and the account of
+// the gcloud configuration when known, sorted by key.
+func (c *Config) Values() []Value {
+	c.mu.RLock()
+	sources := make(map[string]string, len(c.sources))
+	for key, source := range c.sources {
+		sources[key] = source
+	}
+	c.mu.RUnlock()
+
+	values := make([]Value, 0, len(settings)+1)
+	for _, s := range settings {
+		source := sources[s.key]
+		if source == "" {
+			source = SourceDefault
+		}
+		values = append(values, Value{Key: s.key, Value: s.get(c), Source: source})
+	}
+	if account := c.GetAccount(); account != "" {
+		values = append(values, Value{Key: "account", Value: account, Source: SourceGcloud})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Key < values[j].Key })
+	return values
+}
+
+// Path returns the configuration file that was read, or "" when there was
+// none.
+func (c *Config) Path() string {
+	return c.path
+}
@@ -0,0 +1,267 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genericCore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError is returned by SendRequest for a response with a status other
+// than 2xx.
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	// Status, Message and Details are those of the google.rpc.Status that
+	// Google APIs return, e.g. PERMISSION_DENIED.
+	Status  string
+	Message string
+	Details []json.RawMessage
+	// Body is the response body when it is not a Google API error.
+	Body string
+	// RetryAfter is the delay the server asked for before the next attempt.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = strings.TrimSpace(e.Body)
+		if len(msg) > 512 {
+			msg = msg[:512] + "..."
+		}
+	}
+	status := strconv.Itoa(e.StatusCode)
+	if e.Status != "" {
+		status += " " + e.Status
+	}
+	return fmt.Sprintf("%s request to %s failed with HTTP %s: %s", e.Method, e.URL, status, msg)
+}
+
+// Retryable tells whether the request may succeed when sent again, provided
+// sending it again has no side effects.
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// HTTPClient sends authenticated JSON requests to Google APIs. GETs that fail
+// with 429 or a temporary 5xx status, or fail to reach the server, are
+// retried with jittered exponential backoff, or after the delay of a
+// Retry-After header. Requests with side effects are only retried when the
+// server tells they were not acted on: 429, or 503 with a Retry-After header.
+type HTTPClient struct {
+	Client *http.Client
+	// MaxAttempts is how many times a request is sent at most.
+	MaxAttempts int
+	// BaseDelay is the backoff before the second attempt, doubling for every
+	// attempt after it up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultHTTPClient is the HTTPClient of SendRequest.
+var DefaultHTTPClient = &HTTPClient{
+	Client:      &http.Client{Timeout: 30 * time.Second},
+	MaxAttempts: 5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// SendRequest sends a method request to reqURL with DefaultHTTPClient and
+// returns the response body. body, if not nil, is sent as the JSON request
+// body.
+func SendRequest(ctx context.Context, authToken string, method string, reqURL string, body []byte) ([]byte, error) {
+	return DefaultHTTPClient.Send(ctx, authToken, method, reqURL, body)
+}
+
+// Send sends a method request to reqURL, authenticated with authToken, and
+// returns the response body. body, if not nil, is sent as the JSON request
+// body. A response with a status other than 2xx fails with an *APIError.
+// Retries stop when ctx is done, or would end after its deadline, and when
+// the server asks to wait longer than MaxDelay.
+func (c *HTTPClient) Send(ctx context.Context, authToken string, method string, reqURL string, body []byte) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		respBody, err := c.sendOnce(ctx, authToken, method, reqURL, body)
+		if err == nil {
+			return respBody, nil
+		}
+		if attempt >= c.MaxAttempts || !retryable(ctx, method, err) {
+			return nil, err
+		}
+
+		delay := c.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			delay = apiErr.RetryAfter
+		}
+		if delay > c.MaxDelay {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return nil, err
+		}
+		slog.WarnContext(ctx, "Retrying request", "method", method, "url", redactURL(reqURL),
+			"attempt", attempt, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w, gave up retrying: %w", err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable tells whether a request that failed with err is worth sending
+// again.
+func retryable(ctx context.Context, method string, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	// The server may have acted on a request that failed with a 5xx status
+	// or whose response was lost, so only requests without side effects are
+	// sent again then.
+	idempotent := method == http.MethodGet || method == http.MethodHead
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if idempotent {
+			return apiErr.Retryable()
+		}
+		return apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode == http.StatusServiceUnavailable && apiErr.RetryAfter > 0
+	}
+	return idempotent
+}
+
+// backoff returns the delay before the attempt after attempt: half of the
+// exponential delay, plus up to as much again at random.
+func (c *HTTPClient) backoff(attempt int) time.Duration {
+	delay := c.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func (c *HTTPClient) sendOnce(ctx context.Context, authToken string, method string, reqURL string, body []byte) ([]byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("could not create %s request to %s: %w", method, redactURL(reqURL), err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+authToken)
+
+	slog.DebugContext(ctx, "Sending request", "method", method, "url", redactURL(reqURL))
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		// The error of Do names the URL, which may hold a token.
+		var urlErr *url.Error
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		} else if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("%s request to %s failed: %w", method, redactURL(reqURL), err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response of %s request to %s: %w", method, redactURL(reqURL), err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		slog.DebugContext(ctx, "Request failed", "method", method, "url", redactURL(reqURL), "status", resp.StatusCode)
+		return nil, newAPIError(method, redactURL(reqURL), resp, respBody)
+	}
+	return respBody, nil
+}
+
+// newAPIError returns the APIError of resp, whose body is body.
+func newAPIError(method string, reqURL string, resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		Method:     method,
+		URL:        reqURL,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+	var status struct {
+		Error struct {
+			Message string            `json:"message"`
+			Status  string            `json:"status"`
+			Details []json.RawMessage `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &status); err == nil && status.Error.Message != "" {
+		e.Status, e.Message, e.Details = status.Error.Status, status.Error.Message, status.Error.Details
+	} else {
+		e.Body = string(body)
+	}
+	return e
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, given in seconds
+// or as a date, or 0.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// redactURL returns rawURL without the access token some APIs take as a
+// query parameter, for logs and errors.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	if !q.Has("access_token") {
+		return rawURL
+	}
+	q.Set("access_token", "REDACTED")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package genericCore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClient returns an HTTPClient that retries without waiting long.
+func testClient() *HTTPClient {
+	return &HTTPClient{Client: http.DefaultClient, MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+}
+
+func TestSendRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("Authorization header = %q", got)
+		}
+		if body, _ := io.ReadAll(r.Body); r.Method != http.MethodPatch || string(body) != `{"a":1}` {
+			t.Errorf("got %s request with body %q", r.Method, body)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	body, err := testClient().Send(context.Background(), "test-token", http.MethodPatch, srv.URL, []byte(`{"a":1}`))
+	if err != nil || string(body) != `{"ok":true}` {
+		t.Errorf("Send() = %q, %v, want the body of the third attempt", body, err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Send() made %d attempts, want 3", calls.Load())
+	}
+}
+
+func TestSendNoRetryWithSideEffects(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(status)
+		}))
+
+		_, err := testClient().Send(context.Background(), "test-token", http.MethodPost, srv.URL, []byte(`{}`))
+		srv.Close()
+		if err == nil {
+			t.Errorf("Send() of a POST failing with %d succeeded", status)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("Send() sent a POST failing with %d %d times, want 1", status, calls.Load())
+		}
+	}
+}
+
+func TestSendAPIError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":{"code":403,"message":"Permission denied on resource","status":"PERMISSION_DENIED","details":[{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"IAM_PERMISSION_DENIED"}]}}`))
+	}))
+	defer srv.Close()
+
+	_, err := testClient().Send(context.Background(), "test-token", http.MethodGet, srv.URL+"?access_token=secret", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Send() error = %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusForbidden || apiErr.Status != "PERMISSION_DENIED" || len(apiErr.Details) != 1 {
+		t.Errorf("Send() error = %+v, want the status and details of the response", apiErr)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("Send() error %q holds the access token", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Send() made %d attempts of a request failing with 403, want 1", calls.Load())
+	}
+}
+
+func TestSendRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	// The server asks for a longer wait than the client is willing to.
+	_, err := testClient().Send(context.Background(), "test-token", http.MethodGet, srv.URL, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 2*time.Minute {
+		t.Errorf("Send() error = %v, want an *APIError with a Retry-After of 2m", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Send() made %d attempts, want 1", calls.Load())
+	}
+
+	if d := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("parseRetryAfter(date in an hour) = %s", d)
+	}
+}
+
+func TestSendInvalidRequest(t *testing.T) {
+	if _, err := testClient().Send(context.Background(), "test-token", "BAD METHOD", "http://example.com", nil); err == nil {
+		t.Error("Send() with an invalid method succeeded")
+	}
+}
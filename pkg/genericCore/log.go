@@ -0,0 +1,242 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genericCore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Where log records are written to. Stdout carries the MCP messages of the
+// stdio transport, so it may only be used with the HTTP transports.
+const (
+	LogToFile   = "file"
+	LogToStderr = "stderr"
+	LogToStdout = "stdout"
+)
+
+// Formats of log records.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// LogOptions controls the records the default slog logger writes and where.
+type LogOptions struct {
+	// Level is the lowest level written, e.g. "debug" or "info".
+	Level string
+	// Format is LogFormatText or LogFormatJSON.
+	Format string
+	// Output is LogToFile, LogToStderr or LogToStdout.
+	Output string
+	// Dir is the directory a new log file is created in when Output is
+	// LogToFile.
+	Dir string
+}
+
+// moduleRoot is the directory of the module source, which is trimmed off the
+// source location of log records.
+var moduleRoot = func() string {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSuffix(filepath.ToSlash(file), "pkg/genericCore/log.go")
+}()
+
+// ParseLogLevel returns the level named s: debug, info, warn or error,
+// optionally with an offset such as "debug-4".
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, must be one of debug, info, warn, error", s)
+	}
+	return level, nil
+}
+
+// NewLogHandler returns a handler writing the records of opts.Level and above
+// to w in opts.Format. Records carry the module-relative source location of
+// the call that logged them, and the attributes of WithLogAttrs of its
+// context.
+func NewLogHandler(w io.Writer, opts LogOptions) (slog.Handler, error) {
+	level, err := ParseLogLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	handlerOpts := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       level,
+		ReplaceAttr: relativeSource,
+	}
+	switch opts.Format {
+	case LogFormatText, "":
+		return contextHandler{slog.NewTextHandler(w, handlerOpts)}, nil
+	case LogFormatJSON:
+		return contextHandler{slog.NewJSONHandler(w, handlerOpts)}, nil
+	}
+	return nil, fmt.Errorf("invalid log format %q, must be %s or %s", opts.Format, LogFormatText, LogFormatJSON)
+}
+
+var (
+	// logMu guards logFile, the file of the default logger, if any.
+	logMu   sync.Mutex
+	logFile *lazyFile
+)
+
+// Validate checks opts without setting up anything.
+func (opts LogOptions) Validate() error {
+	if _, err := NewLogHandler(io.Discard, opts); err != nil {
+		return err
+	}
+	switch opts.Output {
+	case LogToFile, LogToStderr, LogToStdout, "":
+		return nil
+	}
+	return fmt.Errorf("invalid log output %q, must be one of %s, %s, %s", opts.Output, LogToFile, LogToStderr, LogToStdout)
+}
+
+// SetupLogging makes the default slog logger, and with it the log package,
+// write as opts say.
+func SetupLogging(opts LogOptions) error {
+	var w io.Writer
+	var file *lazyFile
+	switch opts.Output {
+	case LogToFile, "":
+		dir := opts.Dir
+		if dir == "" {
+			dir = DefaultLogDir
+		}
+		file = &lazyFile{root: filepath.Join(dir, "log.cluster-director-mcp")}
+		w = file
+	case LogToStderr:
+		w = os.Stderr
+	case LogToStdout:
+		w = os.Stdout
+	default:
+		return fmt.Errorf("invalid log output %q, must be one of %s, %s, %s", opts.Output, LogToFile, LogToStderr, LogToStdout)
+	}
+	handler, err := NewLogHandler(w, opts)
+	if err != nil {
+		return err
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logFile != nil {
+		logFile.Close()
+	}
+	logFile = file
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// relativeSource replaces the source attribute of a record by the file,
+// relative to the module root, and line it was logged at.
+func relativeSource(groups []string, a slog.Attr) slog.Attr {
+	if a.Key != slog.SourceKey || len(groups) > 0 {
+		return a
+	}
+	src, ok := a.Value.Any().(*slog.Source)
+	if !ok || src == nil {
+		return a
+	}
+	file := strings.TrimPrefix(filepath.ToSlash(src.File), moduleRoot)
+	return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", file, src.Line))
+}
+
+type logAttrsKey struct{}
+
+// WithLogAttrs returns a copy of ctx whose log records carry attrs, in
+// addition to those ctx already adds, e.g. the ID of the request being
+// served.
+func WithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	return context.WithValue(ctx, logAttrsKey{}, append(slices.Clip(prev), attrs...))
+}
+
+// contextHandler adds the attributes of WithLogAttrs of the context of a
+// record to it.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(logAttrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// NewCorrelationID returns a random ID that ties together the log records of
+// a request or tool call.
+func NewCorrelationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// lazyFile is a log file that is created, under a name starting with root not
+// taken yet, on the first write, so that runs that log nothing leave no file
+// behind.
+type lazyFile struct {
+	root string
+
+	mu     sync.Mutex
+	f      *os.File
+	closed bool
+}
+
+func (l *lazyFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil && !l.closed {
+		l.f = CreateUniqueFilePath(l.root)
+	}
+	if l.f == nil {
+		// The log file cannot be written, stderr is the next best place.
+		return os.Stderr.Write(p)
+	}
+	return l.f.Write(p)
+}
+
+func (l *lazyFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
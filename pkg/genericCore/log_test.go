@@ -0,0 +1,92 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package genericCore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogHandlerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	handler, err := NewLogHandler(&buf, LogOptions{Level: "info", Format: LogFormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(handler)
+
+	ctx := WithLogAttrs(context.Background(), slog.String("request_id", "r1"))
+	ctx = WithLogAttrs(ctx, slog.String("call_id", "c1"))
+	logger.DebugContext(ctx, "hidden")
+	logger.InfoContext(ctx, "shown", "cluster", "c")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("logged %d records, want only the info one:\n%s", len(lines), buf.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("record %s is not JSON: %v", lines[0], err)
+	}
+	for key, want := range map[string]string{"level": "INFO", "msg": "shown", "cluster": "c", "request_id": "r1", "call_id": "c1"} {
+		if record[key] != want {
+			t.Errorf("record[%q] = %v, want %q", key, record[key], want)
+		}
+	}
+	if src, _ := record["source"].(string); !strings.HasPrefix(src, "pkg/genericCore/log_test.go:") {
+		t.Errorf("record source = %q, want a module-relative location", src)
+	}
+}
+
+func TestLogOptionsErrors(t *testing.T) {
+	for _, opts := range []LogOptions{
+		{Level: "verbose"},
+		{Level: "info", Format: "xml"},
+	} {
+		if _, err := NewLogHandler(&bytes.Buffer{}, opts); err == nil {
+			t.Errorf("NewLogHandler(%+v) succeeded", opts)
+		}
+	}
+	if err := SetupLogging(LogOptions{Level: "info", Output: "syslog"}); err == nil {
+		t.Error("SetupLogging() with an unknown output succeeded")
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "log.cluster-director-mcp")
+	if err := os.WriteFile(root+".0", nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer slog.SetDefault(slog.Default())
+	if err := SetupLogging(LogOptions{Level: "info", Output: LogToFile, Dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	slog.Info("first")
+	// Close the log file.
+	if err := SetupLogging(LogOptions{Level: "info", Output: LogToStderr}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(root + ".1")
+	if err != nil || !strings.Contains(string(data), "msg=first") {
+		t.Errorf("log file holds %q, %v, want the record after the existing log file", data, err)
+	}
+}
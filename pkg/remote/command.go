@@ -0,0 +1,233 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Limits of the commands run by an Executor.
+const (
+	DefaultCommandTimeout = 2 * time.Minute
+	DefaultMaxOutput      = 16 * 1024 * 1024
+)
+
+// Command is a program to run on a node. Commands are never written as shell
+// text: the program is looked up in an Allowlist and every argument is checked
+// by it and quoted, so no argument can be interpreted as shell syntax.
+type Command struct {
+	// Program is the name of the program in the Allowlist, e.g. "squeue".
+	Program string
+	Args    []string
+	// Stdin, if not nil, is sent to the standard input of the program.
+	Stdin []byte
+}
+
+func (c Command) String() string {
+	words := []string{c.Program}
+	for _, a := range c.Args {
+		words = append(words, Quote(a))
+	}
+	return strings.Join(words, " ")
+}
+
+// Validator checks the value of an argument.
+type Validator func(value string) error
+
+// Matches returns a Validator accepting the values matched by re, which
+// should be anchored.
+func Matches(re *regexp.Regexp) Validator {
+	return func(value string) error {
+		if !re.MatchString(value) {
+			return fmt.Errorf("%q does not match %s", value, re)
+		}
+		return nil
+	}
+}
+
+// OneOf returns a Validator accepting only values.
+func OneOf(values ...string) Validator {
+	return func(value string) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %q", value, values)
+	}
+}
+
+// Program describes the arguments a program may be run with.
+type Program struct {
+	// Path is the absolute path of the program on the node.
+	Path string
+	// Subcommands are the leading arguments the program must be run with,
+	// e.g. "show nodes". When empty the program has no subcommands.
+	Subcommands []string
+	// Flags are the options the program may be given, by name. Options with
+	// a Validator take a value, which must be passed as --name=value. Options
+	// mapped to nil take none.
+	Flags map[string]Validator
+	// Operands validates the arguments that are not options. When nil the
+	// program takes none.
+	Operands Validator
+	// Stdin allows sending data to the standard input of the program.
+	Stdin bool
+}
+
+// ErrNotAllowed is wrapped by the errors of commands an Allowlist rejects.
+var ErrNotAllowed = errors.New("remote command not allowed")
+
+// Allowlist holds the programs that may be run, by name.
+type Allowlist map[string]Program
+
+// CommandLine checks c against a and returns it as text for the remote shell.
+func (a Allowlist) CommandLine(c Command) (string, error) {
+	p, ok := a[c.Program]
+	if !ok {
+		return "", fmt.Errorf("%w: %s is not in the allowlist", ErrNotAllowed, c.Program)
+	}
+	if c.Stdin != nil && !p.Stdin {
+		return "", fmt.Errorf("%w: %s does not read standard input", ErrNotAllowed, c.Program)
+	}
+	args := c.Args
+	words := []string{p.Path}
+	if len(p.Subcommands) > 0 {
+		sub, ok := matchSubcommand(p.Subcommands, args)
+		if !ok {
+			return "", fmt.Errorf("%w: %s must be run with one of the subcommands %q", ErrNotAllowed, c.Program, p.Subcommands)
+		}
+		words = append(words, sub...)
+		args = args[len(sub):]
+	}
+	for _, arg := range args {
+		if err := p.check(arg); err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrNotAllowed, c.Program, err)
+		}
+		words = append(words, Quote(arg))
+	}
+	return strings.Join(words, " "), nil
+}
+
+// matchSubcommand returns the leading words of args that form one of subs.
+func matchSubcommand(subs []string, args []string) ([]string, bool) {
+	for _, s := range subs {
+		words := strings.Fields(s)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == s {
+			return words, true
+		}
+	}
+	return nil, false
+}
+
+// check validates an argument of p that follows the subcommand.
+func (p Program) check(arg string) error {
+	if !strings.HasPrefix(arg, "-") {
+		if p.Operands == nil {
+			return fmt.Errorf("unexpected argument %q", arg)
+		}
+		return p.Operands(arg)
+	}
+	name, value, hasValue := strings.Cut(arg, "=")
+	validate, ok := p.Flags[name]
+	switch {
+	case !ok:
+		return fmt.Errorf("option %s is not allowed", name)
+	case validate == nil && hasValue:
+		return fmt.Errorf("option %s takes no value", name)
+	case validate != nil && !hasValue:
+		return fmt.Errorf("option %s needs a value", name)
+	case validate != nil:
+		if err := validate(value); err != nil {
+			return fmt.Errorf("option %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// safeWord matches the words that need no quoting.
+var safeWord = regexp.MustCompile(`^[A-Za-z0-9@%+=:,./_-]+$`)
+
+// Quote quotes s for the POSIX shell.
+func Quote(s string) string {
+	if safeWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Transport runs command lines on nodes. *Runner is the Transport used in
+// production.
+type Transport interface {
+	Run(ctx context.Context, node Node, cmd string, opts RunOptions) (string, error)
+}
+
+// TransportFunc adapts a function to a Transport.
+type TransportFunc func(ctx context.Context, node Node, cmd string, opts RunOptions) (string, error)
+
+// Run calls f.
+func (f TransportFunc) Run(ctx context.Context, node Node, cmd string, opts RunOptions) (string, error) {
+	return f(ctx, node, cmd, opts)
+}
+
+// Executor runs the commands of an Allowlist over a Transport, bounding how
+// long they run and how much they print.
+type Executor struct {
+	transport Transport
+	allowed   Allowlist
+	timeout   time.Duration
+	maxOutput int
+}
+
+// NewExecutor returns an Executor running the commands of allowed over t with
+// the default limits.
+func NewExecutor(t Transport, allowed Allowlist) *Executor {
+	return &Executor{
+		transport: t,
+		allowed:   allowed,
+		timeout:   DefaultCommandTimeout,
+		maxOutput: DefaultMaxOutput,
+	}
+}
+
+// SetLimits changes how long commands may run and how many bytes they may
+// print on stdout.
+func (e *Executor) SetLimits(timeout time.Duration, maxOutput int) {
+	e.timeout, e.maxOutput = timeout, maxOutput
+}
+
+// Run runs c on node and returns what it printed on stdout.
+func (e *Executor) Run(ctx context.Context, node Node, c Command) (string, error) {
+	line, err := e.allowed.CommandLine(c)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	output, err := e.transport.Run(ctx, node, line, RunOptions{Stdin: c.Stdin, MaxOutput: e.maxOutput})
+	var connectErr *ConnectError
+	if err != nil && !errors.As(err, &connectErr) && ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%s did not finish within %s", c.Program, e.timeout)
+	}
+	if err != nil {
+		return output, fmt.Errorf("%s: %w", c.Program, err)
+	}
+	return output, nil
+}
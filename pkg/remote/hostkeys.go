@@ -0,0 +1,95 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/oauth2"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/auth"
+	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
+)
+
+const DefaultComputeEndpoint = "https://compute.googleapis.com/compute/v1"
+
+// HostKeySource returns the host keys a node is known to have.
+type HostKeySource interface {
+	// HostKeys returns the host keys of node, or none if the node did not
+	// publish any.
+	HostKeys(ctx context.Context, node Node) ([]ssh.PublicKey, error)
+}
+
+// GuestAttributes is a HostKeySource that reads the host keys the guest
+// environment of an instance publishes under the hostkeys/ guest attributes,
+// as gcloud compute ssh does.
+type GuestAttributes struct {
+	// Tokens are the OAuth access tokens of the user.
+	Tokens oauth2.TokenSource
+	// Endpoint is the root of the Compute Engine API, DefaultComputeEndpoint
+	// when empty.
+	Endpoint string
+}
+
+// HostKeys returns the host keys published by node. Instances without guest
+// attributes, or whose guest environment published no keys, have none.
+func (g *GuestAttributes) HostKeys(ctx context.Context, node Node) ([]ssh.PublicKey, error) {
+	token, err := auth.AccessToken(g.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := g.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultComputeEndpoint
+	}
+	reqURL := fmt.Sprintf("%s/projects/%s/zones/%s/instances/%s/getGuestAttributes?queryPath=%s", endpoint,
+		url.PathEscape(node.Project), url.PathEscape(node.Zone), url.PathEscape(node.Instance), url.QueryEscape("hostkeys/"))
+	body, err := genericCore.SendRequest(ctx, token, http.MethodGet, reqURL, nil)
+	var apiErr *genericCore.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get the host keys of %s: %w", node.Instance, err)
+	}
+	var resp struct {
+		QueryValue struct {
+			Items []struct {
+				Key   string `json:"key"`
+				Value string `json:"value"`
+			} `json:"items"`
+		} `json:"queryValue"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("could not parse the guest attributes of %s: %w", node.Instance, err)
+	}
+	var keys []ssh.PublicKey
+	for _, item := range resp.QueryValue.Items {
+		// The key of the attribute is the key type, e.g. ssh-ed25519, and
+		// its value the base64 key.
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(item.Key + " " + item.Value))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the %s host key of %s: %w", item.Key, node.Instance, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
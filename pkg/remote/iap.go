@@ -0,0 +1,232 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+	"golang.org/x/oauth2"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/auth"
+)
+
+// IAP TCP forwarding relay protocol, as spoken by `gcloud compute ssh
+// --tunnel-through-iap`.
+const (
+	DefaultIAPEndpoint = "wss://tunnel.cloudproxy.app/v4"
+
+	iapSubprotocol = "relay.tunnel.cloudproxy.app"
+	iapOrigin      = "bot:iap-tunneler"
+
+	iapTagConnectSuccessSID   = 0x0001
+	iapTagReconnectSuccessAck = 0x0002
+	iapTagData                = 0x0004
+	iapTagAck                 = 0x0007
+
+	// iapMaxData is the largest payload of a data frame.
+	iapMaxData = 16 * 1024
+)
+
+// ErrIAPDenied is returned by IAPDialer when IAP refuses the tunnel, because
+// the user lacks the IAP-secured Tunnel User role or no firewall rule lets IAP
+// reach the node.
+var ErrIAPDenied = errors.New("IAP denied the tunnel")
+
+// IAPDialer connects to nodes through an Identity-Aware Proxy TCP forwarding
+// tunnel, so nodes without an external IP address can be reached. The caller
+// needs the IAP-secured Tunnel User role and a firewall rule letting
+// 35.235.240.0/20 reach the port.
+type IAPDialer struct {
+	// Tokens are the OAuth access tokens the tunnel is opened with.
+	Tokens oauth2.TokenSource
+	// Endpoint is the root of the relay, DefaultIAPEndpoint when empty.
+	Endpoint string
+	// Interface is the network interface of the node to connect to, nic0
+	// when empty.
+	Interface string
+	// Port is the port of the node to connect to, 22 when zero.
+	Port int
+}
+
+// DialContext opens a tunnel to node and waits for the relay to connect it.
+func (d *IAPDialer) DialContext(ctx context.Context, node Node) (net.Conn, error) {
+	endpoint, nic, port := d.Endpoint, d.Interface, d.Port
+	if endpoint == "" {
+		endpoint = DefaultIAPEndpoint
+	}
+	if nic == "" {
+		nic = "nic0"
+	}
+	if port == 0 {
+		port = 22
+	}
+	query := url.Values{
+		"project":      {node.Project},
+		"zone":         {node.Zone},
+		"instance":     {node.Instance},
+		"interface":    {nic},
+		"port":         {strconv.Itoa(port)},
+		"newWebsocket": {"true"},
+	}
+	config, err := websocket.NewConfig(endpoint+"/connect?"+query.Encode(), iapOrigin)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IAP endpoint %q: %w", endpoint, err)
+	}
+	config.Protocol = []string{iapSubprotocol}
+	token, err := auth.AccessToken(d.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	config.Header = http.Header{"Authorization": {"Bearer " + token}}
+
+	ws, err := config.DialContext(ctx)
+	var dialErr *websocket.DialError
+	if errors.As(err, &dialErr) && dialErr.Err == websocket.ErrBadStatus {
+		return nil, fmt.Errorf("could not open IAP tunnel to %s: %w", node.Instance, ErrIAPDenied)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open IAP tunnel: %w", err)
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	if deadline, ok := ctx.Deadline(); ok {
+		ws.SetDeadline(deadline)
+	}
+
+	conn := &iapConn{ws: ws}
+	frame, err := conn.receive()
+	if err != nil {
+		ws.Close()
+		if errors.Is(err, io.EOF) {
+			// IAP closes the tunnel when the user may not use it or the
+			// node cannot be reached from IAP.
+			err = fmt.Errorf("%w: %w", ErrIAPDenied, err)
+		}
+		return nil, fmt.Errorf("IAP tunnel to %s was not connected: %w", node.Instance, err)
+	}
+	if tag := binary.BigEndian.Uint16(frame); tag != iapTagConnectSuccessSID {
+		ws.Close()
+		return nil, fmt.Errorf("IAP tunnel to %s sent frame %#04x before connecting", node.Instance, tag)
+	}
+	ws.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// iapConn is a net.Conn over an IAP relay websocket.
+type iapConn struct {
+	ws *websocket.Conn
+
+	// pending is the part of the last data frame not read yet.
+	pending []byte
+	// received counts the bytes of data received, acked the ones acknowledged
+	// to the relay.
+	received, acked uint64
+
+	writeMu sync.Mutex
+}
+
+// receive reads a frame of at least a tag.
+func (c *iapConn) receive() ([]byte, error) {
+	var frame []byte
+	if err := websocket.Message.Receive(c.ws, &frame); err != nil {
+		return nil, err
+	}
+	if len(frame) < 2 {
+		return nil, fmt.Errorf("short IAP frame of %d bytes", len(frame))
+	}
+	return frame, nil
+}
+
+func (c *iapConn) send(frame []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return websocket.Message.Send(c.ws, frame)
+}
+
+func (c *iapConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		frame, err := c.receive()
+		if err != nil {
+			return 0, err
+		}
+		switch tag := binary.BigEndian.Uint16(frame); tag {
+		case iapTagData:
+			if len(frame) < 6 || int(binary.BigEndian.Uint32(frame[2:])) != len(frame)-6 {
+				return 0, fmt.Errorf("malformed IAP data frame of %d bytes", len(frame))
+			}
+			c.pending = frame[6:]
+			c.received += uint64(len(c.pending))
+			if c.received-c.acked >= 2*iapMaxData {
+				if err := c.ack(); err != nil {
+					return 0, err
+				}
+			}
+		case iapTagAck, iapTagReconnectSuccessAck:
+			// Acknowledgements of the data sent are only needed to resume
+			// a broken tunnel, which is never done.
+		default:
+			return 0, fmt.Errorf("unexpected IAP frame %#04x", tag)
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// ack acknowledges the data received so far.
+func (c *iapConn) ack() error {
+	frame := make([]byte, 10)
+	binary.BigEndian.PutUint16(frame, iapTagAck)
+	binary.BigEndian.PutUint64(frame[2:], c.received)
+	if err := c.send(frame); err != nil {
+		return err
+	}
+	c.acked = c.received
+	return nil
+}
+
+func (c *iapConn) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), iapMaxData)
+		frame := make([]byte, 6+n)
+		binary.BigEndian.PutUint16(frame, iapTagData)
+		binary.BigEndian.PutUint32(frame[2:], uint32(n))
+		copy(frame[6:], p[:n])
+		if err := c.send(frame); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (c *iapConn) Close() error                       { return c.ws.Close() }
+func (c *iapConn) LocalAddr() net.Addr                { return c.ws.LocalAddr() }
+func (c *iapConn) RemoteAddr() net.Addr               { return c.ws.RemoteAddr() }
+func (c *iapConn) SetDeadline(t time.Time) error      { return c.ws.SetDeadline(t) }
+func (c *iapConn) SetReadDeadline(t time.Time) error  { return c.ws.SetReadDeadline(t) }
+func (c *iapConn) SetWriteDeadline(t time.Time) error { return c.ws.SetWriteDeadline(t) }
@@ -0,0 +1,182 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/oauth2"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/auth"
+	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
+)
+
+const (
+	DefaultOSLoginEndpoint   = "https://oslogin.googleapis.com/v1"
+	DefaultTokenInfoEndpoint = "https://oauth2.googleapis.com/tokeninfo"
+	// DefaultKeyTTL is how long the keys registered with OS Login are valid.
+	DefaultKeyTTL = time.Hour
+)
+
+// OSLogin is an Authenticator that generates an ed25519 key in memory and
+// registers it with the OS Login profile of the user the access token belongs
+// to. The key is never written to disk and expires after KeyTTL, after which a
+// new one is registered.
+type OSLogin struct {
+	// Tokens are the OAuth access tokens of the user.
+	Tokens oauth2.TokenSource
+	// Endpoint is the root of the OS Login API, DefaultOSLoginEndpoint when
+	// empty.
+	Endpoint string
+	// TokenInfoEndpoint tells who the access token belongs to,
+	// DefaultTokenInfoEndpoint when empty.
+	TokenInfoEndpoint string
+	// KeyTTL is the lifetime of the keys, DefaultKeyTTL when zero.
+	KeyTTL time.Duration
+
+	mu      sync.Mutex
+	user    string
+	signer  ssh.Signer
+	expires time.Time
+}
+
+// Credentials returns the POSIX user name of the OS Login profile and the
+// key registered with it.
+func (o *OSLogin) Credentials(ctx context.Context) (string, ssh.Signer, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// Leave commands started with the key time to log in.
+	if o.signer != nil && time.Now().Add(time.Minute).Before(o.expires) {
+		return o.user, o.signer, nil
+	}
+
+	token, err := auth.AccessToken(o.Tokens)
+	if err != nil {
+		return "", nil, err
+	}
+	email, err := o.email(ctx, token)
+	if err != nil {
+		return "", nil, err
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not generate ssh key: %w", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not use ssh key: %w", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not use ssh key: %w", err)
+	}
+
+	ttl := o.KeyTTL
+	if ttl == 0 {
+		ttl = DefaultKeyTTL
+	}
+	expires := time.Now().Add(ttl)
+	user, err := o.importKey(ctx, token, email, sshPub, expires)
+	if err != nil {
+		return "", nil, err
+	}
+	slog.InfoContext(ctx, "Registered OS Login key", "fingerprint", ssh.FingerprintSHA256(sshPub), "email", email, "user", user)
+	o.user, o.signer, o.expires = user, signer, expires
+	return user, signer, nil
+}
+
+// Forget drops the registered key, so that the next call to Credentials
+// registers a new one for the user the tokens then belong to.
+func (o *OSLogin) Forget() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.user, o.signer, o.expires = "", nil, time.Time{}
+}
+
+// email returns the account token belongs to.
+func (o *OSLogin) email(ctx context.Context, token string) (string, error) {
+	endpoint := o.TokenInfoEndpoint
+	if endpoint == "" {
+		endpoint = DefaultTokenInfoEndpoint
+	}
+	body, err := genericCore.SendRequest(ctx, token, http.MethodGet, endpoint+"?access_token="+url.QueryEscape(token), nil)
+	if err != nil {
+		return "", fmt.Errorf("could not look up the account of the access token: %w", err)
+	}
+	var info struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return "", fmt.Errorf("could not parse token info: %w", err)
+	}
+	if info.Email == "" {
+		return "", fmt.Errorf("the access token has no email, it needs the userinfo.email scope")
+	}
+	return info.Email, nil
+}
+
+// importKey registers key with the OS Login profile of email until expires and
+// returns the POSIX user name of the profile.
+func (o *OSLogin) importKey(ctx context.Context, token string, email string, key ssh.PublicKey, expires time.Time) (string, error) {
+	endpoint := o.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultOSLoginEndpoint
+	}
+	req, err := json.Marshal(map[string]string{
+		"key":                strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))),
+		"expirationTimeUsec": strconv.FormatInt(expires.UnixMicro(), 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	reqURL := endpoint + "/users/" + url.PathEscape(email) + ":importSshPublicKey"
+	body, err := genericCore.SendRequest(ctx, token, http.MethodPost, reqURL, req)
+	if err != nil {
+		return "", fmt.Errorf("could not register ssh key with OS Login for %s: %w", email, err)
+	}
+	var resp struct {
+		LoginProfile struct {
+			PosixAccounts []struct {
+				Primary  bool   `json:"primary"`
+				Username string `json:"username"`
+			} `json:"posixAccounts"`
+		} `json:"loginProfile"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("could not parse OS Login profile: %w", err)
+	}
+	accounts := resp.LoginProfile.PosixAccounts
+	for _, a := range accounts {
+		if a.Primary {
+			return a.Username, nil
+		}
+	}
+	if len(accounts) > 0 {
+		return accounts[0].Username, nil
+	}
+	return "", fmt.Errorf("the OS Login profile of %s has no POSIX account", email)
+}
@@ -0,0 +1,364 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remote runs commands on Compute Engine instances over SSH without
+// shelling out to gcloud. Connections are opened by a pluggable Dialer, by
+// default an IAP TCP forwarding tunnel, and authenticated with a key
+// registered through OS Login.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultTimeout bounds how long a connection to a node may take to be
+// established and authenticated.
+const DefaultTimeout = 30 * time.Second
+
+// Node identifies a Compute Engine instance.
+type Node struct {
+	Project  string
+	Zone     string
+	Instance string
+}
+
+func (n Node) String() string {
+	return fmt.Sprintf("projects/%s/zones/%s/instances/%s", n.Project, n.Zone, n.Instance)
+}
+
+// Dialer opens a connection to the SSH port of a node.
+type Dialer interface {
+	DialContext(ctx context.Context, node Node) (net.Conn, error)
+}
+
+// DialerFunc adapts a function to a Dialer.
+type DialerFunc func(ctx context.Context, node Node) (net.Conn, error)
+
+// DialContext calls f.
+func (f DialerFunc) DialContext(ctx context.Context, node Node) (net.Conn, error) {
+	return f(ctx, node)
+}
+
+// Authenticator returns the user name and key to log in to nodes with.
+type Authenticator interface {
+	Credentials(ctx context.Context) (user string, signer ssh.Signer, err error)
+}
+
+// ExitError is returned by Runner.Run when the command ran but exited with a
+// non-zero status.
+type ExitError struct {
+	Status int
+	Stderr string
+}
+
+func (e *ExitError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("command exited with status %d", e.Status)
+	}
+	return fmt.Sprintf("command exited with status %d: %s", e.Status, e.Stderr)
+}
+
+// ConnectError is returned by Runner.Run when the node could not be reached
+// or logged in to. The command was not run, so it is safe to try another node.
+type ConnectError struct {
+	Node Node
+	Err  error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("could not connect to %s: %v", e.Node.Instance, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error { return e.Err }
+
+// Runner runs commands on nodes over SSH. Connections are kept open and reused
+// by later commands on the same node.
+type Runner struct {
+	dialer Dialer
+	auth   Authenticator
+	// hostKeyCallback verifies the host key of nodes. When nil, the key a
+	// node presents must be one of those keySource returns for it. Nodes that
+	// publish no keys are trusted on first use: the first key they present is
+	// accepted and any other key they present later in the life of the Runner
+	// is rejected.
+	hostKeyCallback ssh.HostKeyCallback
+	keySource       HostKeySource
+	timeout         time.Duration
+
+	mu       sync.Mutex
+	clients  map[Node]*ssh.Client
+	hostKeys map[Node]ssh.PublicKey
+}
+
+// NewRunner returns a Runner that connects to nodes with dialer and logs in
+// with the credentials of auth.
+func NewRunner(dialer Dialer, auth Authenticator) *Runner {
+	return &Runner{
+		dialer:   dialer,
+		auth:     auth,
+		timeout:  DefaultTimeout,
+		clients:  make(map[Node]*ssh.Client),
+		hostKeys: make(map[Node]ssh.PublicKey),
+	}
+}
+
+// SetHostKeyCallback replaces the check of host keys.
+func (r *Runner) SetHostKeyCallback(cb ssh.HostKeyCallback) {
+	r.hostKeyCallback = cb
+}
+
+// SetHostKeySource sets where the host keys nodes must present are looked up.
+// Without one, every node is trusted on first use.
+func (r *Runner) SetHostKeySource(source HostKeySource) {
+	r.keySource = source
+}
+
+// maxStderr bounds the standard error kept for ExitError.
+const maxStderr = 64 * 1024
+
+// RunOptions are the input and limits of a command run by Runner.Run.
+type RunOptions struct {
+	// Stdin, if not nil, is sent to the standard input of the command.
+	Stdin []byte
+	// MaxOutput, if not zero, is the most bytes the command may print on
+	// stdout. The command is stopped when it prints more.
+	MaxOutput int
+}
+
+// OutputTooLargeError is returned by Runner.Run when a command printed more
+// than RunOptions.MaxOutput bytes.
+type OutputTooLargeError struct {
+	Limit int
+}
+
+func (e *OutputTooLargeError) Error() string {
+	return fmt.Sprintf("command printed more than %d bytes", e.Limit)
+}
+
+// limitedBuffer keeps up to limit bytes. Once more are written it calls
+// overflow, if not nil, and fails the writes.
+type limitedBuffer struct {
+	// buf is not embedded, so that io.Copy cannot bypass Write through
+	// bytes.Buffer.ReadFrom.
+	buf      bytes.Buffer
+	limit    int
+	overflow func()
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && b.buf.Len()+len(p) > b.limit {
+		if !b.exceeded && b.overflow != nil {
+			b.overflow()
+		}
+		b.exceeded = true
+		n := b.limit - b.buf.Len()
+		b.buf.Write(p[:n])
+		if b.overflow == nil {
+			// Truncate silently.
+			return len(p), nil
+		}
+		return n, &OutputTooLargeError{Limit: b.limit}
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+// Run runs cmd with the login shell of the user on node and returns what it
+// printed on stdout. cmd is interpreted by the remote shell, so its arguments
+// must be quoted by the caller, which Executor does.
+func (r *Runner) Run(ctx context.Context, node Node, cmd string, opts RunOptions) (string, error) {
+	client, err := r.client(ctx, node)
+	if err != nil {
+		return "", err
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		// The cached connection went away, e.g. because the tunnel was
+		// closed while idle. Reconnect once.
+		r.forget(node, client)
+		if client, err = r.client(ctx, node); err != nil {
+			return "", err
+		}
+		if session, err = client.NewSession(); err != nil {
+			r.forget(node, client)
+			return "", &ConnectError{Node: node, Err: fmt.Errorf("could not open ssh session: %w", err)}
+		}
+	}
+	defer session.Close()
+
+	stdout := &limitedBuffer{limit: opts.MaxOutput, overflow: func() { session.Close() }}
+	stderr := &limitedBuffer{limit: maxStderr}
+	session.Stdout = stdout
+	session.Stderr = stderr
+	if opts.Stdin != nil {
+		session.Stdin = bytes.NewReader(opts.Stdin)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(cmd) }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		return "", ctx.Err()
+	}
+
+	if stdout.exceeded {
+		return "", &OutputTooLargeError{Limit: opts.MaxOutput}
+	}
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.String(), &ExitError{Status: exitErr.ExitStatus(), Stderr: strings.TrimSpace(stderr.String())}
+	}
+	if err != nil {
+		r.forget(node, client)
+		return "", fmt.Errorf("could not run command on %s: %w", node.Instance, err)
+	}
+	return stdout.String(), nil
+}
+
+// Close closes all the connections of r.
+func (r *Runner) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for node, client := range r.clients {
+		errs = append(errs, client.Close())
+		delete(r.clients, node)
+	}
+	return errors.Join(errs...)
+}
+
+// client returns the open connection to node, connecting first if there is
+// none.
+func (r *Runner) client(ctx context.Context, node Node) (*ssh.Client, error) {
+	r.mu.Lock()
+	client, ok := r.clients[node]
+	r.mu.Unlock()
+	if ok {
+		return client, nil
+	}
+
+	user, signer, err := r.auth.Credentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get ssh credentials: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	hostKeyCallback, err := r.checkHostKey(ctx, node)
+	if err != nil {
+		return nil, &ConnectError{Node: node, Err: err}
+	}
+	conn, err := r.dialer.DialContext(ctx, node)
+	if err != nil {
+		return nil, &ConnectError{Node: node, Err: err}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	config := &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         r.timeout,
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, node.Instance, config)
+	if err != nil {
+		conn.Close()
+		return nil, &ConnectError{Node: node, Err: fmt.Errorf("could not log in as %s: %w", user, err)}
+	}
+	conn.SetDeadline(time.Time{})
+	client = ssh.NewClient(c, chans, reqs)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.clients[node]; ok {
+		// Another command connected to node at the same time.
+		client.Close()
+		return existing, nil
+	}
+	r.clients[node] = client
+	return client, nil
+}
+
+// forget closes client and drops it from the open connections if it is still
+// the connection to node.
+func (r *Runner) forget(node Node, client *ssh.Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.clients[node] == client {
+		delete(r.clients, node)
+	}
+	client.Close()
+}
+
+// checkHostKey returns the host key callback for node. Unless a key of node
+// was already accepted, the keys it published are looked up first.
+func (r *Runner) checkHostKey(ctx context.Context, node Node) (ssh.HostKeyCallback, error) {
+	if r.hostKeyCallback != nil {
+		return r.hostKeyCallback, nil
+	}
+	r.mu.Lock()
+	_, pinned := r.hostKeys[node]
+	r.mu.Unlock()
+	var published []ssh.PublicKey
+	if !pinned && r.keySource != nil {
+		var err error
+		if published, err = r.keySource.HostKeys(ctx, node); err != nil {
+			return nil, err
+		}
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if len(published) > 0 && !containsKey(published, key) {
+			return fmt.Errorf("host key %s of %s is not one of the keys the instance published", ssh.FingerprintSHA256(key), node.Instance)
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		known, ok := r.hostKeys[node]
+		if !ok {
+			r.hostKeys[node] = key
+			return nil
+		}
+		if !bytes.Equal(known.Marshal(), key.Marshal()) {
+			return fmt.Errorf("host key of %s changed from %s to %s", node.Instance,
+				ssh.FingerprintSHA256(known), ssh.FingerprintSHA256(key))
+		}
+		return nil
+	}, nil
+}
+
+// containsKey tells whether key is one of keys.
+func containsKey(keys []ssh.PublicKey, key ssh.PublicKey) bool {
+	for _, k := range keys {
+		if bytes.Equal(k.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,479 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package remote
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/websocket"
+	"golang.org/x/oauth2"
+)
+
+var testTokens = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})
+
+// staticAuth logs in as user with signer.
+type staticAuth struct {
+	user   string
+	signer ssh.Signer
+}
+
+func (a staticAuth) Credentials(ctx context.Context) (string, ssh.Signer, error) {
+	return a.user, a.signer, nil
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// sshServer is a local stand-in for the SSH server of a node. It only lets
+// user log in with the key of auth, and answers every command with run.
+type sshServer struct {
+	addr    string
+	hostKey ssh.PublicKey
+	dials   atomic.Int32
+}
+
+func startSSHServer(t *testing.T, auth staticAuth, run func(cmd string, stdin io.Reader) (string, uint32)) *sshServer {
+	t.Helper()
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() != auth.user || string(key.Marshal()) != string(auth.signer.PublicKey().Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	hostKey := newSigner(t)
+	config.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &sshServer{addr: ln.Addr().String(), hostKey: hostKey.PublicKey()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, config, run)
+		}
+	}()
+	return s
+}
+
+func serveSSH(conn net.Conn, config *ssh.ServerConfig, run func(cmd string, stdin io.Reader) (string, uint32)) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				ssh.Unmarshal(req.Payload, &payload)
+				req.Reply(true, nil)
+				out, status := run(payload.Command, channel)
+				if status == 0 {
+					io.WriteString(channel, out)
+				} else {
+					io.WriteString(channel.Stderr(), out)
+				}
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+				return
+			}
+		}()
+	}
+}
+
+// dialer connects to s directly, whatever the node.
+func (s *sshServer) dialer() Dialer {
+	return DialerFunc(func(ctx context.Context, node Node) (net.Conn, error) {
+		s.dials.Add(1)
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", s.addr)
+	})
+}
+
+func echoCommand(cmd string, stdin io.Reader) (string, uint32) {
+	if strings.HasPrefix(cmd, "fail") {
+		return "no such command", 127
+	}
+	if cmd == "cat" {
+		in, _ := io.ReadAll(stdin)
+		return string(in), 0
+	}
+	return "ran " + cmd + "\n", 0
+}
+
+func TestRunner(t *testing.T) {
+	auth := staticAuth{user: "alice_example_com", signer: newSigner(t)}
+	server := startSSHServer(t, auth, echoCommand)
+	runner := NewRunner(server.dialer(), auth)
+	defer runner.Close()
+	node := Node{Project: "p", Zone: "us-central1-a", Instance: "login-001"}
+	ctx := context.Background()
+
+	for _, cmd := range []string{"sinfo", "squeue --me"} {
+		got, err := runner.Run(ctx, node, cmd, RunOptions{})
+		if err != nil {
+			t.Fatalf("Run(%q) returned error: %v", cmd, err)
+		}
+		if want := "ran " + cmd + "\n"; got != want {
+			t.Errorf("Run(%q) = %q, want %q", cmd, got, want)
+		}
+	}
+	if n := server.dials.Load(); n != 1 {
+		t.Errorf("Runner dialed %d times for two commands on one node, want 1", n)
+	}
+
+	_, err := runner.Run(ctx, node, "fail", RunOptions{})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Status != 127 || exitErr.Stderr != "no such command" {
+		t.Errorf("Run(fail) returned error %v, want exit status 127 with stderr", err)
+	}
+
+	// A command run after the connection broke reconnects.
+	runner.mu.Lock()
+	runner.clients[node].Close()
+	runner.mu.Unlock()
+	if _, err := runner.Run(ctx, node, "sinfo", RunOptions{}); err != nil {
+		t.Errorf("Run after the connection closed returned error: %v", err)
+	}
+	if n := server.dials.Load(); n != 2 {
+		t.Errorf("Runner dialed %d times after the connection closed, want 2", n)
+	}
+
+	if got, err := runner.Run(ctx, node, "cat", RunOptions{Stdin: []byte("#!/bin/bash\n")}); err != nil || got != "#!/bin/bash\n" {
+		t.Errorf("Run(cat) with stdin = %q, %v", got, err)
+	}
+	_, err = runner.Run(ctx, node, "sinfo", RunOptions{MaxOutput: 4})
+	var tooLarge *OutputTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Errorf("Run with a 4 byte output limit returned %v, want OutputTooLargeError", err)
+	}
+
+	// Logging in with another key is refused.
+	other := NewRunner(server.dialer(), staticAuth{user: auth.user, signer: newSigner(t)})
+	if _, err := other.Run(ctx, node, "sinfo", RunOptions{}); err == nil {
+		t.Error("Run with an unregistered key succeeded")
+	}
+}
+
+func TestRunnerHostKeyChange(t *testing.T) {
+	auth := staticAuth{user: "alice", signer: newSigner(t)}
+	first := startSSHServer(t, auth, echoCommand)
+	second := startSSHServer(t, auth, echoCommand)
+	node := Node{Project: "p", Zone: "z", Instance: "login-001"}
+
+	var target atomic.Pointer[sshServer]
+	target.Store(first)
+	runner := NewRunner(DialerFunc(func(ctx context.Context, node Node) (net.Conn, error) {
+		return net.Dial("tcp", target.Load().addr)
+	}), auth)
+	defer runner.Close()
+
+	if _, err := runner.Run(context.Background(), node, "sinfo", RunOptions{}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	runner.Close()
+	target.Store(second)
+	_, err := runner.Run(context.Background(), node, "sinfo", RunOptions{})
+	if err == nil || !strings.Contains(err.Error(), "host key") {
+		t.Errorf("Run against a node with another host key returned %v, want host key error", err)
+	}
+}
+
+// guestAttributes is a stand-in for the Compute Engine API that publishes keys
+// as the host keys of login-001, or none when keys is nil.
+func guestAttributes(t *testing.T, keys ...ssh.PublicKey) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/p/zones/z/instances/login-001/getGuestAttributes" || r.URL.Query().Get("queryPath") != "hostkeys/" || keys == nil {
+			http.NotFound(w, r)
+			return
+		}
+		type item struct {
+			Namespace string `json:"namespace"`
+			Key       string `json:"key"`
+			Value     string `json:"value"`
+		}
+		var items []item
+		for _, key := range keys {
+			fields := strings.Fields(string(ssh.MarshalAuthorizedKey(key)))
+			items = append(items, item{Namespace: "hostkeys", Key: fields[0], Value: fields[1]})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"queryValue": map[string]any{"items": items}})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestRunnerPublishedHostKeys(t *testing.T) {
+	auth := staticAuth{user: "alice", signer: newSigner(t)}
+	server := startSSHServer(t, auth, echoCommand)
+	node := Node{Project: "p", Zone: "z", Instance: "login-001"}
+
+	tests := []struct {
+		name    string
+		keys    []ssh.PublicKey
+		wantErr bool
+	}{
+		{name: "published", keys: []ssh.PublicKey{newSigner(t).PublicKey(), server.hostKey}},
+		{name: "mismatch", keys: []ssh.PublicKey{newSigner(t).PublicKey()}, wantErr: true},
+		{name: "none published"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := NewRunner(server.dialer(), auth)
+			defer runner.Close()
+			runner.SetHostKeySource(&GuestAttributes{Tokens: testTokens, Endpoint: guestAttributes(t, tc.keys...).URL})
+
+			_, err := runner.Run(context.Background(), node, "sinfo", RunOptions{})
+			if tc.wantErr {
+				var connErr *ConnectError
+				if !errors.As(err, &connErr) || !strings.Contains(err.Error(), "not one of the keys") {
+					t.Errorf("Run returned %v, want a ConnectError about the host key", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Run returned error: %v", err)
+			}
+		})
+	}
+}
+
+// iapRelay is a stand-in for the IAP TCP forwarding relay that connects every
+// tunnel to target.
+func iapRelay(t *testing.T, target string) *httptest.Server {
+	t.Helper()
+	relay := websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			if req.Header.Get("Authorization") != "Bearer token" {
+				return errors.New("unauthorized")
+			}
+			if len(config.Protocol) != 1 || config.Protocol[0] != iapSubprotocol {
+				return errors.New("wrong subprotocol")
+			}
+			q := req.URL.Query()
+			if q.Get("instance") != "login-001" || q.Get("zone") != "z" || q.Get("port") != "22" {
+				return errors.New("wrong target " + req.URL.RawQuery)
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			ws.PayloadType = websocket.BinaryFrame
+			conn, err := net.Dial("tcp", target)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			sid := []byte("sid")
+			frame := binary.BigEndian.AppendUint16(nil, iapTagConnectSuccessSID)
+			frame = binary.BigEndian.AppendUint32(frame, uint32(len(sid)))
+			websocket.Message.Send(ws, append(frame, sid...))
+
+			go func() {
+				buf := make([]byte, 1000)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						ws.Close()
+						return
+					}
+					frame := binary.BigEndian.AppendUint16(nil, iapTagData)
+					frame = binary.BigEndian.AppendUint32(frame, uint32(n))
+					websocket.Message.Send(ws, append(frame, buf[:n]...))
+				}
+			}()
+			for {
+				var frame []byte
+				if err := websocket.Message.Receive(ws, &frame); err != nil {
+					return
+				}
+				if binary.BigEndian.Uint16(frame) == iapTagData {
+					conn.Write(frame[6:])
+				}
+			}
+		},
+	}
+	s := httptest.NewServer(relay)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestIAPDialer(t *testing.T) {
+	auth := staticAuth{user: "alice", signer: newSigner(t)}
+	server := startSSHServer(t, auth, echoCommand)
+	relay := iapRelay(t, server.addr)
+
+	dialer := &IAPDialer{Tokens: testTokens, Endpoint: "ws" + strings.TrimPrefix(relay.URL, "http")}
+	runner := NewRunner(dialer, auth)
+	defer runner.Close()
+
+	// Enough output to need several data frames and acknowledgements.
+	long := strings.Repeat("x", 3*iapMaxData)
+	got, err := runner.Run(context.Background(), Node{Project: "p", Zone: "z", Instance: "login-001"}, long, RunOptions{})
+	if err != nil {
+		t.Fatalf("Run through the IAP tunnel returned error: %v", err)
+	}
+	if got != "ran "+long+"\n" {
+		t.Errorf("Run through the IAP tunnel returned %d bytes, want %d", len(got), len(long)+5)
+	}
+
+	if _, err := runner.Run(context.Background(), Node{Project: "p", Zone: "z", Instance: "login-002"}, "sinfo", RunOptions{}); err == nil {
+		t.Error("Run on a node the relay refuses succeeded")
+	}
+}
+
+func TestOSLogin(t *testing.T) {
+	var imported atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tokeninfo", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"email": "alice@example.com"}`)
+	})
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/alice@example.com:importSshPublicKey" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Key                string `json:"key"`
+			ExpirationTimeUsec string `json:"expirationTimeUsec"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if !strings.HasPrefix(req.Key, "ssh-ed25519 ") || req.ExpirationTimeUsec == "" {
+			http.Error(w, "bad key", http.StatusBadRequest)
+			return
+		}
+		imported.Add(1)
+		io.WriteString(w, `{"loginProfile": {"posixAccounts": [
+			{"username": "other"},
+			{"primary": true, "username": "alice_example_com"}
+		]}}`)
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	o := &OSLogin{Tokens: testTokens, Endpoint: s.URL, TokenInfoEndpoint: s.URL + "/tokeninfo"}
+	user, signer, err := o.Credentials(context.Background())
+	if err != nil {
+		t.Fatalf("Credentials returned error: %v", err)
+	}
+	if user != "alice_example_com" || signer == nil {
+		t.Errorf("Credentials returned user %q, want alice_example_com", user)
+	}
+	again, _, err := o.Credentials(context.Background())
+	if err != nil || again != user {
+		t.Errorf("second Credentials returned %q, %v", again, err)
+	}
+	if n := imported.Load(); n != 1 {
+		t.Errorf("key was imported %d times, want 1", n)
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	allowed := Allowlist{
+		"scontrol": {
+			Path:        "/usr/local/bin/scontrol",
+			Subcommands: []string{"show nodes", "hold"},
+			Flags:       map[string]Validator{"--json": nil},
+			Operands:    Matches(regexp.MustCompile(`^[0-9]+$`)),
+		},
+		"squeue": {
+			Path:  "/usr/local/bin/squeue",
+			Flags: map[string]Validator{"--noheader": nil, "--format": OneOf("%i|%u")},
+		},
+	}
+	for _, tc := range []struct {
+		cmd  Command
+		want string
+	}{
+		{Command{Program: "scontrol", Args: []string{"show", "nodes", "--json"}}, "/usr/local/bin/scontrol show nodes --json"},
+		{Command{Program: "scontrol", Args: []string{"hold", "42"}}, "/usr/local/bin/scontrol hold 42"},
+		{Command{Program: "squeue", Args: []string{"--noheader", "--format=%i|%u"}}, "/usr/local/bin/squeue --noheader '--format=%i|%u'"},
+	} {
+		got, err := allowed.CommandLine(tc.cmd)
+		if err != nil || got != tc.want {
+			t.Errorf("CommandLine(%v) = %q, %v, want %q", tc.cmd, got, err, tc.want)
+		}
+	}
+
+	for _, bad := range []Command{
+		{Program: "rm", Args: []string{"-rf", "/"}},
+		{Program: "scontrol", Args: []string{"delete", "nodes"}},
+		{Program: "scontrol", Args: []string{"hold", "42; reboot"}},
+		{Program: "scontrol", Args: []string{"hold", "--json=1"}},
+		{Program: "squeue", Args: []string{"--format=%i|%u;id"}},
+		{Program: "squeue", Args: []string{"--noheader", "--me"}},
+		{Program: "squeue", Args: []string{"--format"}},
+		{Program: "squeue", Stdin: []byte("x")},
+	} {
+		if got, err := allowed.CommandLine(bad); !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("CommandLine(%v) = %q, want an error", bad, got)
+		}
+	}
+
+	if got := Quote("it's"); got != `'it'\''s'` {
+		t.Errorf("Quote(it's) = %s", got)
+	}
+
+	slow := TransportFunc(func(ctx context.Context, node Node, cmd string, opts RunOptions) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	e := NewExecutor(slow, allowed)
+	e.SetLimits(10*time.Millisecond, 0)
+	_, err := e.Run(context.Background(), Node{}, Command{Program: "scontrol", Args: []string{"show", "nodes"}})
+	if err == nil || !strings.Contains(err.Error(), "did not finish") {
+		t.Errorf("Run of a command that never finishes returned %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"sync"
+	"time"
+)
+
+// How long API responses are served from the cache. Regions and zones rarely
+// change; clusters change with every operation, and the tools that change
+// them invalidate what they touch.
+const (
+	locationsTTL   = time.Hour
+	clusterListTTL = 2 * time.Minute
+	clusterTTL     = 30 * time.Second
+	// fleetSnapshotTTL is how long the later pages of list_clusters can be
+	// read.
+	fleetSnapshotTTL = 10 * time.Minute
+)
+
+// ttlCache is a map safe for concurrent use whose entries expire ttl after
+// they were stored. Entries of a cache with a zero ttl never expire.
+type ttlCache[K comparable, V any] struct {
+	ttl time.Duration
+	// now is replaced by tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[K]cacheEntry[V]
+}
+
+type cacheEntry[V any] struct {
+	value   V
+	fetched time.Time
+}
+
+func newTTLCache[K comparable, V any](ttl time.Duration) *ttlCache[K, V] {
+	return &ttlCache[K, V]{ttl: ttl, now: time.Now, entries: make(map[K]cacheEntry[V])}
+}
+
+// get returns the value stored for key and when it was stored. ok is false
+// when there is no value or it has expired.
+func (c *ttlCache[K, V]) get(key K) (value V, fetched time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return value, fetched, false
+	}
+	if c.ttl > 0 && c.now().Sub(e.fetched) >= c.ttl {
+		delete(c.entries, key)
+		return value, fetched, false
+	}
+	return e.value, e.fetched, true
+}
+
+// set stores value for key as fetched now.
+func (c *ttlCache[K, V]) set(key K, value V) {
+	c.setFetched(key, value, c.now())
+}
+
+// setFetched stores value for key as fetched at fetched, e.g. when it was
+// taken from a listing made earlier.
+func (c *ttlCache[K, V]) setFetched(key K, value V, fetched time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry[V]{value: value, fetched: fetched}
+}
+
+func (c *ttlCache[K, V]) delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// prune drops the expired entries.
+func (c *ttlCache[K, V]) prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if c.ttl > 0 && c.now().Sub(e.fetched) >= c.ttl {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// clear drops every entry.
+func (c *ttlCache[K, V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[K]cacheEntry[V])
+}
+
+// cacheAge renders how old data fetched at fetched is, for tool output.
+func cacheAge(fetched time.Time) string {
+	return time.Since(fetched).Round(time.Second).String()
+}
+
+// clusterState is the output of get_cluster.
+type clusterState struct {
+	*Cluster
+	// CacheAge is how long ago the cluster was fetched.
+	CacheAge string `json:"cacheAge"`
+}
+
+// forgetClusterState invalidates what is cached about the cluster with
+// resource name name, and the listing of its region, after it was changed.
+func (h *handlers) forgetClusterState(name string) {
+	h.clusters.delete(name)
+	h.clusterLists.delete(regionTarget{project: ProjectOf(name), region: LocationOf(name)})
+}
+
+// resetCaches forgets everything cached.
+func (h *handlers) resetCaches() {
+	h.locations.clear()
+	h.clusterLists.clear()
+	h.clusters.clear()
+	h.fleetPages.clear()
+	h.slurmBackendFor.clear()
+	h.loginNodeFor.clear()
+}
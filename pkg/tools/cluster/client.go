@@ -0,0 +1,133 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned by a ClusterDirectorClient when the requested
+// resource does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ClusterDirectorClient is the subset of the Cluster Director
+// (hypercomputecluster.googleapis.com) API used by the tools in this package,
+// along with the Compute Engine and Resource Manager calls they need.
+// Resource names are always fully qualified, e.g.
+// projects/<project>/locations/<location>/clusters/<cluster>.
+type ClusterDirectorClient interface {
+	// ListLocations returns the locations (regions) Cluster Director supports
+	// for projectID. List calls return the items of every page.
+	ListLocations(ctx context.Context, projectID string) ([]Location, error)
+	// ListZones returns the Compute Engine zones in region.
+	ListZones(ctx context.Context, projectID string, region string) ([]string, error)
+	// ListClusters returns the clusters in a single location.
+	ListClusters(ctx context.Context, projectID string, location string) ([]Cluster, error)
+	// GetCluster returns a single cluster by its resource name.
+	GetCluster(ctx context.Context, name string) (*Cluster, error)
+	// CreateCluster starts creating cluster with ID clusterID in location.
+	CreateCluster(ctx context.Context, projectID string, location string, clusterID string, cluster *Cluster) (*Operation, error)
+	// UpdateCluster starts updating the fields of cluster named in updateMask.
+	// cluster.Name identifies the cluster to update.
+	UpdateCluster(ctx context.Context, cluster *Cluster, updateMask []string) (*Operation, error)
+	// DeleteCluster starts deleting the cluster with resource name name.
+	DeleteCluster(ctx context.Context, name string) (*Operation, error)
+	// CallSlurm forwards req to the slurmrestd of the cluster with resource
+	// name name.
+	CallSlurm(ctx context.Context, name string, req *SlurmRequest) (*SlurmResponse, error)
+	// ListOperations returns the long-running operations in a single location.
+	ListOperations(ctx context.Context, projectID string, location string) ([]Operation, error)
+	// GetOperation returns a single long-running operation by its resource name.
+	GetOperation(ctx context.Context, name string) (*Operation, error)
+	// SearchProjects returns the IDs of the projects matching a Resource
+	// Manager projects.search query, e.g. "parent:folders/123 labels.env:prod".
+	SearchProjects(ctx context.Context, query string) ([]string, error)
+}
+
+// Location is a region in which Cluster Director can create clusters.
+type Location struct {
+	Name       string `json:"name"`
+	LocationID string `json:"locationId"`
+}
+
+// Operation is a google.longrunning.Operation returned by mutating Cluster
+// Director calls.
+type Operation struct {
+	Name     string            `json:"name"`
+	Metadata OperationMetadata `json:"metadata"`
+	Done     bool              `json:"done"`
+	Error    *OperationError   `json:"error,omitempty"`
+	Response json.RawMessage   `json:"response,omitempty"`
+}
+
+// OperationMetadata describes the progress of an Operation.
+type OperationMetadata struct {
+	CreateTime            string `json:"createTime"`
+	EndTime               string `json:"endTime"`
+	Target                string `json:"target"`
+	Verb                  string `json:"verb"`
+	StatusMessage         string `json:"statusMessage"`
+	RequestedCancellation bool   `json:"requestedCancellation"`
+	APIVersion            string `json:"apiVersion"`
+}
+
+// OperationError is the google.rpc.Status of a failed Operation.
+type OperationError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// LocationOf returns the location ID of a location-scoped resource name.
+func LocationOf(name string) string {
+	parts := strings.Split(name, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "locations" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// ProjectOf returns the project ID of a project-scoped resource name.
+func ProjectOf(name string) string {
+	parts := strings.Split(name, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "projects" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// LocationName returns the resource name of a location.
+func LocationName(projectID string, location string) string {
+	return fmt.Sprintf("projects/%s/locations/%s", projectID, location)
+}
+
+// ClusterResourceName returns the resource name of a cluster.
+func ClusterResourceName(projectID string, location string, clusterID string) string {
+	return fmt.Sprintf("%s/clusters/%s", LocationName(projectID, location), clusterID)
+}
+
+// ShortName returns the last segment of a resource name, e.g. the cluster ID
+// of a cluster resource name.
+func ShortName(name string) string {
+	return path.Base(name)
+}
@@ -0,0 +1,340 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeClient is an in-memory ClusterDirectorClient. It lets the tools be
+// exercised without network access or credentials.
+type FakeClient struct {
+	mu sync.Mutex
+	// zones maps a project/location resource name to its zones.
+	zones map[string][]string
+	// clusters and operations are keyed by resource name.
+	clusters   map[string]Cluster
+	operations map[string]Operation
+	// nextOp numbers the operations started by mutating calls.
+	nextOp int
+	// slurm maps a cluster resource name and slurmrestd path to the response
+	// body of CallSlurm.
+	slurm map[string]map[string]string
+	// projects maps a project ID to the project found by SearchProjects.
+	projects map[string]fakeProject
+	// listErrors maps a location resource name to the error of ListClusters
+	// in it.
+	listErrors map[string]error
+	// operationErrors maps a location resource name to the error of
+	// ListOperations in it.
+	operationErrors map[string]error
+	// slurmErrors maps a cluster resource name to the error of CallSlurm.
+	slurmErrors map[string]error
+}
+
+// fakeProject is a project in the Resource Manager hierarchy.
+type fakeProject struct {
+	parent string
+	labels map[string]string
+}
+
+// NewFakeClient returns an empty FakeClient.
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		zones:           make(map[string][]string),
+		clusters:        make(map[string]Cluster),
+		operations:      make(map[string]Operation),
+		slurm:           make(map[string]map[string]string),
+		projects:        make(map[string]fakeProject),
+		listErrors:      make(map[string]error),
+		slurmErrors:     make(map[string]error),
+		operationErrors: make(map[string]error),
+	}
+}
+
+// FailListClusters makes ListClusters in location of projectID fail with err.
+func (f *FakeClient) FailListClusters(projectID string, location string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listErrors[LocationName(projectID, location)] = err
+}
+
+// FailListOperations makes ListOperations in location of projectID fail with
+// err.
+func (f *FakeClient) FailListOperations(projectID string, location string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.operationErrors[LocationName(projectID, location)] = err
+}
+
+// FailCallSlurm makes CallSlurm on the cluster called name fail with err.
+func (f *FakeClient) FailCallSlurm(name string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.slurmErrors[name] = err
+}
+
+// AddProject makes SearchProjects find projectID under parent, e.g.
+// folders/123, with labels.
+func (f *FakeClient) AddProject(projectID string, parent string, labels map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.projects[projectID] = fakeProject{parent: parent, labels: labels}
+}
+
+// SetSlurmResponse makes CallSlurm on the cluster with resource name cluster
+// return body for path. CallSlurm fails for clusters without any response.
+func (f *FakeClient) SetSlurmResponse(cluster string, path string, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.slurm[cluster] == nil {
+		f.slurm[cluster] = make(map[string]string)
+	}
+	f.slurm[cluster][path] = body
+}
+
+// AddLocation registers location (and its zones) as supported in projectID.
+func (f *FakeClient) AddLocation(projectID string, location string, zones ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.zones[LocationName(projectID, location)] = zones
+}
+
+// AddCluster stores cluster under its resource name, registering its location
+// if needed.
+func (f *FakeClient) AddCluster(cluster Cluster) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	loc := parentOf(cluster.Name, "clusters")
+	if _, ok := f.zones[loc]; !ok {
+		f.zones[loc] = nil
+	}
+	f.clusters[cluster.Name] = cluster
+}
+
+// AddOperation stores op under its resource name.
+func (f *FakeClient) AddOperation(op Operation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.operations[op.Name] = op
+}
+
+// parentOf returns the part of name before the /<collection>/ segment.
+func parentOf(name string, collection string) string {
+	if i := strings.Index(name, "/"+collection+"/"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+func (f *FakeClient) ListLocations(ctx context.Context, projectID string) ([]Location, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var locations []Location
+	for name := range f.zones {
+		if strings.HasPrefix(name, "projects/"+projectID+"/") {
+			locations = append(locations, Location{Name: name, LocationID: ShortName(name)})
+		}
+	}
+	sort.Slice(locations, func(i, j int) bool { return locations[i].Name < locations[j].Name })
+	return locations, nil
+}
+
+func (f *FakeClient) ListZones(ctx context.Context, projectID string, region string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	zones, ok := f.zones[LocationName(projectID, region)]
+	if !ok {
+		return nil, fmt.Errorf("region %s: %w", region, ErrNotFound)
+	}
+	return zones, nil
+}
+
+func (f *FakeClient) ListClusters(ctx context.Context, projectID string, location string) ([]Cluster, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parent := LocationName(projectID, location)
+	if err := f.listErrors[parent]; err != nil {
+		return nil, err
+	}
+	var clusters []Cluster
+	for name, c := range f.clusters {
+		if parentOf(name, "clusters") == parent {
+			clusters = append(clusters, c)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
+	return clusters, nil
+}
+
+func (f *FakeClient) GetCluster(ctx context.Context, name string) (*Cluster, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("cluster %s: %w", name, ErrNotFound)
+	}
+	return &c, nil
+}
+
+// newOperation records a completed operation on target and returns it. The
+// caller must hold f.mu.
+func (f *FakeClient) newOperation(location string, verb string, target string, response interface{}) *Operation {
+	f.nextOp++
+	op := Operation{
+		Name: fmt.Sprintf("%s/operations/operation-%d", location, f.nextOp),
+		Metadata: OperationMetadata{
+			CreateTime: time.Now().UTC().Format(time.RFC3339),
+			EndTime:    time.Now().UTC().Format(time.RFC3339),
+			Target:     target,
+			Verb:       verb,
+		},
+		Done: true,
+	}
+	if response != nil {
+		op.Response, _ = json.Marshal(response)
+	}
+	f.operations[op.Name] = op
+	return &op
+}
+
+func (f *FakeClient) CreateCluster(ctx context.Context, projectID string, location string, clusterID string, cluster *Cluster) (*Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := ClusterResourceName(projectID, location, clusterID)
+	if _, ok := f.clusters[name]; ok {
+		return nil, fmt.Errorf("cluster %s already exists", name)
+	}
+	created := *cluster
+	created.Name = name
+	created.CreateTime = time.Now().UTC().Format(time.RFC3339)
+	created.UpdateTime = created.CreateTime
+	f.clusters[name] = created
+	return f.newOperation(LocationName(projectID, location), "create", name, created), nil
+}
+
+// UpdateCluster replaces the stored cluster with cluster. Only the
+// orchestrator.slurm.nodeSets field can be updated.
+func (f *FakeClient) UpdateCluster(ctx context.Context, cluster *Cluster, updateMask []string) (*Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, ok := f.clusters[cluster.Name]
+	if !ok {
+		return nil, fmt.Errorf("cluster %s: %w", cluster.Name, ErrNotFound)
+	}
+	for _, field := range updateMask {
+		switch field {
+		case "orchestrator.slurm.nodeSets":
+			stored.Orchestrator.Slurm.NodeSets = cluster.Orchestrator.Slurm.NodeSets
+		default:
+			return nil, fmt.Errorf("updating %s is not supported", field)
+		}
+	}
+	stored.UpdateTime = time.Now().UTC().Format(time.RFC3339)
+	f.clusters[cluster.Name] = stored
+	return f.newOperation(parentOf(cluster.Name, "clusters"), "update", cluster.Name, stored), nil
+}
+
+func (f *FakeClient) DeleteCluster(ctx context.Context, name string) (*Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.clusters[name]; !ok {
+		return nil, fmt.Errorf("cluster %s: %w", name, ErrNotFound)
+	}
+	delete(f.clusters, name)
+	return f.newOperation(parentOf(name, "clusters"), "delete", name, nil), nil
+}
+
+func (f *FakeClient) CallSlurm(ctx context.Context, name string, req *SlurmRequest) (*SlurmResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.slurmErrors[name]; err != nil {
+		return nil, err
+	}
+	responses, ok := f.slurm[name]
+	if !ok {
+		return nil, fmt.Errorf("CallSlurm is not available for cluster %s", name)
+	}
+	body, ok := responses[req.Path]
+	if !ok {
+		return &SlurmResponse{StatusCode: 404, BodyJSON: `{"errors":[{"error":"Unable find requested URL"}]}`}, nil
+	}
+	return &SlurmResponse{StatusCode: 200, BodyJSON: body}, nil
+}
+
+func (f *FakeClient) ListOperations(ctx context.Context, projectID string, location string) ([]Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parent := LocationName(projectID, location)
+	if err := f.operationErrors[parent]; err != nil {
+		return nil, err
+	}
+	var ops []Operation
+	for name, op := range f.operations {
+		if parentOf(name, "operations") == parent {
+			ops = append(ops, op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Name < ops[j].Name })
+	return ops, nil
+}
+
+func (f *FakeClient) GetOperation(ctx context.Context, name string) (*Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	op, ok := f.operations[name]
+	if !ok {
+		return nil, fmt.Errorf("operation %s: %w", name, ErrNotFound)
+	}
+	return &op, nil
+}
+
+// SearchProjects understands the parent:, labels.<key>: and state: terms of
+// projects.search queries.
+func (f *FakeClient) SearchProjects(ctx context.Context, query string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var projects []string
+	for id, p := range f.projects {
+		match := true
+		for _, term := range strings.Fields(query) {
+			field, value, ok := strings.Cut(term, ":")
+			if !ok {
+				return nil, fmt.Errorf("unsupported query term %q", term)
+			}
+			switch {
+			case field == "parent":
+				match = match && p.parent == value
+			case field == "state":
+			case strings.HasPrefix(field, "labels."):
+				v, ok := p.labels[strings.TrimPrefix(field, "labels.")]
+				match = match && ok && (value == "*" || v == value)
+			default:
+				return nil, fmt.Errorf("unsupported query term %q", term)
+			}
+		}
+		if match {
+			projects = append(projects, id)
+		}
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
@@ -0,0 +1,283 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	cloudresourcemanager "google.golang.org/api/cloudresourcemanager/v3"
+	compute "google.golang.org/api/compute/v0.alpha"
+	"google.golang.org/api/option"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/auth"
+	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
+)
+
+// httpClient is the ClusterDirectorClient that talks to the real REST API.
+type httpClient struct {
+	// endpoint is the versioned API root, e.g.
+	// https://hypercomputecluster.googleapis.com/v1alpha
+	endpoint string
+	tokens   oauth2.TokenSource
+
+	computeOnce    sync.Once
+	computeService *compute.Service
+	computeErr     error
+
+	resourceManagerOnce    sync.Once
+	resourceManagerService *cloudresourcemanager.Service
+	resourceManagerErr     error
+}
+
+// NewHTTPClient returns a ClusterDirectorClient for the REST API rooted at
+// endpoint, authenticated with the access tokens of tokens.
+func NewHTTPClient(endpoint string, tokens oauth2.TokenSource) ClusterDirectorClient {
+	return &httpClient{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		tokens:   tokens,
+	}
+}
+
+// get issues a GET for the resource at path (relative to the endpoint) and
+// decodes the JSON response into out.
+func (c *httpClient) get(ctx context.Context, path string, out interface{}) error {
+	return c.send(ctx, http.MethodGet, path, nil, out)
+}
+
+// send issues a method request for the resource at path (relative to the
+// endpoint) with in, if not nil, as the JSON body, and decodes the JSON
+// response into out.
+func (c *httpClient) send(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	reqURL := c.endpoint + "/" + path
+	var reqBody []byte
+	if in != nil {
+		var err error
+		if reqBody, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("could not marshal request to %s: %w", reqURL, err)
+		}
+	}
+	token, err := auth.AccessToken(c.tokens)
+	if err != nil {
+		return err
+	}
+	body, err := genericCore.SendRequest(ctx, token, method, reqURL, reqBody)
+	var apiErr *genericCore.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("could not parse response from %s: %w", reqURL, err)
+	}
+	return nil
+}
+
+// listAll returns the items of every page of the collection at path (relative
+// to the endpoint), found under field of each List response, following
+// nextPageToken until the last page.
+func listAll[T any](ctx context.Context, c *httpClient, path string, field string) ([]T, error) {
+	var all []T
+	token := ""
+	for {
+		items, next, err := listPage[T](ctx, c, path, field, token)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		if next == "" {
+			return all, nil
+		}
+		if next == token {
+			return nil, fmt.Errorf("listing %s returned page token %q twice", path, next)
+		}
+		token = next
+	}
+}
+
+// listPage returns the page of the collection at path starting at pageToken,
+// the first page if empty, and the token of the next page, empty after the
+// last one.
+func listPage[T any](ctx context.Context, c *httpClient, path string, field string, pageToken string) ([]T, string, error) {
+	if pageToken != "" {
+		path += "?pageToken=" + url.QueryEscape(pageToken)
+	}
+	var resp map[string]json.RawMessage
+	if err := c.get(ctx, path, &resp); err != nil {
+		return nil, "", err
+	}
+	var items []T
+	if raw, ok := resp[field]; ok {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, "", fmt.Errorf("could not parse %s of %s: %w", field, path, err)
+		}
+	}
+	var next string
+	if raw, ok := resp["nextPageToken"]; ok {
+		if err := json.Unmarshal(raw, &next); err != nil {
+			return nil, "", fmt.Errorf("could not parse nextPageToken of %s: %w", path, err)
+		}
+	}
+	return items, next, nil
+}
+
+func (c *httpClient) ListLocations(ctx context.Context, projectID string) ([]Location, error) {
+	// Equivalent CURL command:
+	// curl \
+	// -H "Content-Type:application/json" \
+	// -H "Authorization: Bearer $(gcloud auth print-access-token)" \
+	// https://hypercomputecluster.googleapis.com/v1alpha/projects/hpc-toolkit-dev/locations/
+	//
+	// {
+	//   "locations": [
+	//     {
+	//       "name": "projects/hpc-toolkit-dev/locations/asia-southeast1",
+	//       "locationId": "asia-southeast1"
+	//     },
+	//     {
+	//       "name": "projects/hpc-toolkit-dev/locations/us-central1",
+	//       "locationId": "us-central1"
+	//     }
+	//   ],
+	//   "nextPageToken": "..."
+	// }
+	return listAll[Location](ctx, c, "projects/"+projectID+"/locations", "locations")
+}
+
+func (c *httpClient) ListZones(ctx context.Context, projectID string, region string) ([]string, error) {
+	c.computeOnce.Do(func() {
+		c.computeService, c.computeErr = compute.NewService(context.Background(), option.WithTokenSource(c.tokens))
+	})
+	if c.computeErr != nil {
+		return nil, fmt.Errorf("could not create compute service: %w", c.computeErr)
+	}
+
+	var zonesList []string
+
+	// The filter string tells the API to return only zones whose region name
+	// matches the one we specified.
+	filter := fmt.Sprintf("name=%s-*", region)
+
+	// The 'Pages' method handles pagination for you. We process each page of results.
+	req := c.computeService.Zones.List(projectID).Filter(filter)
+	if err := req.Pages(ctx, func(page *compute.ZoneList) error {
+		for _, zone := range page.Items {
+			zonesList = append(zonesList, zone.Name)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("could not list zones for project %s in region %s: %w", projectID, region, err)
+	}
+	return zonesList, nil
+}
+
+func (c *httpClient) ListClusters(ctx context.Context, projectID string, location string) ([]Cluster, error) {
+	// Equivalent CURL command:
+	// curl \
+	// -H "Content-Type:application/json" \
+	// -H "Authorization: Bearer $(gcloud auth print-access-token)" \
+	// https://hypercomputecluster.googleapis.com/v1alpha/projects/hpc-toolkit-dev/locations/us-central1/clusters
+	//
+	// A region without clusters returns an empty object. See
+	// testdata/clusters.json for a full example of the response.
+	return listAll[Cluster](ctx, c, LocationName(projectID, location)+"/clusters", "clusters")
+}
+
+func (c *httpClient) GetCluster(ctx context.Context, name string) (*Cluster, error) {
+	var cluster Cluster
+	if err := c.get(ctx, name, &cluster); err != nil {
+		return nil, err
+	}
+	return &cluster, nil
+}
+
+func (c *httpClient) CreateCluster(ctx context.Context, projectID string, location string, clusterID string, cluster *Cluster) (*Operation, error) {
+	var op Operation
+	path := LocationName(projectID, location) + "/clusters?clusterId=" + url.QueryEscape(clusterID)
+	if err := c.send(ctx, http.MethodPost, path, cluster, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+func (c *httpClient) UpdateCluster(ctx context.Context, cluster *Cluster, updateMask []string) (*Operation, error) {
+	var op Operation
+	path := cluster.Name + "?updateMask=" + url.QueryEscape(strings.Join(updateMask, ","))
+	if err := c.send(ctx, http.MethodPatch, path, cluster, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+func (c *httpClient) DeleteCluster(ctx context.Context, name string) (*Operation, error) {
+	var op Operation
+	if err := c.send(ctx, http.MethodDelete, name, nil, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+func (c *httpClient) CallSlurm(ctx context.Context, name string, req *SlurmRequest) (*SlurmResponse, error) {
+	// Equivalent to the CallSlurm RPC:
+	// name: "projects/cloud-hypercomp-dev/locations/us-central1/clusters/clusterob9",
+	// user: "google", method: "GET", path: "/slurm/v0.0.42/nodes/", body_json: ""
+	var resp SlurmResponse
+	if err := c.send(ctx, http.MethodPost, name+":callSlurm", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *httpClient) ListOperations(ctx context.Context, projectID string, location string) ([]Operation, error) {
+	return listAll[Operation](ctx, c, LocationName(projectID, location)+"/operations", "operations")
+}
+
+func (c *httpClient) GetOperation(ctx context.Context, name string) (*Operation, error) {
+	var op Operation
+	if err := c.get(ctx, name, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+func (c *httpClient) SearchProjects(ctx context.Context, query string) ([]string, error) {
+	c.resourceManagerOnce.Do(func() {
+		c.resourceManagerService, c.resourceManagerErr = cloudresourcemanager.NewService(context.Background(), option.WithTokenSource(c.tokens))
+	})
+	if c.resourceManagerErr != nil {
+		return nil, fmt.Errorf("could not create resource manager service: %w", c.resourceManagerErr)
+	}
+
+	var projects []string
+	req := c.resourceManagerService.Projects.Search().Query(query)
+	if err := req.Pages(ctx, func(page *cloudresourcemanager.SearchProjectsResponse) error {
+		for _, p := range page.Projects {
+			projects = append(projects, p.ProjectId)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("could not search projects matching %q: %w", query, err)
+	}
+	return projects, nil
+}
@@ -16,163 +16,495 @@ package cluster
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/oauth2"
 
+	"github.com/nadig-google/cluster-director-mcp/pkg/auth"
 	"github.com/nadig-google/cluster-director-mcp/pkg/config"
-	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
+	"github.com/nadig-google/cluster-director-mcp/pkg/remote"
 )
 
 //	"google.golang.org/api/option"
 //	"google.golang.org/protobuf/encoding/protojson"
 
 type handlers struct {
-	c *config.Config
+	c   *config.Config
+	api ClusterDirectorClient
+	// server is the MCP server the tools are registered on.
+	server *server.MCPServer
+
+	// locations caches the regions Cluster Director supports in a project,
+	// mapped to their zones.
+	locations *ttlCache[string, map[string][]string]
+	// clusterLists caches the clusters of a region of a project.
+	clusterLists *ttlCache[regionTarget, []Cluster]
+	// fleetPages keeps the listings of list_clusters that further pages are
+	// read from, by snapshot ID.
+	fleetPages *ttlCache[string, FleetListing]
+	// clusters caches single clusters as last fetched, keyed by their
+	// resource name, as clusters of the same name may be in several regions.
+	clusters *ttlCache[string, Cluster]
+
+	// planKey signs the confirmation tokens handed out with mutation plans.
+	planKey []byte
+	// pollInterval is how often long-running operations are polled.
+	pollInterval time.Duration
+	// fanOut is how many API calls a tool makes at once.
+	fanOut int
+
+	// slurmBackendFor remembers which Slurm backend answered for a cluster
+	// when the backend is chosen automatically. It and loginNodeFor are keyed
+	// by the resource name of the cluster.
+	slurmBackendFor *ttlCache[string, string]
+	// loginNodeFor remembers which login node of a cluster answered last.
+	loginNodeFor *ttlCache[string, string]
+	// tokens authenticate the calls of the tools, and osLogin the
+	// connections to login nodes with a key registered for their user.
+	tokens  oauth2.TokenSource
+	osLogin *remote.OSLogin
+	// runRemote runs a command on a cluster node for the ssh Slurm backend.
+	runRemote func(ctx context.Context, node remote.Node, cmd remote.Command) (string, error)
 }
 
+func newHandlers(c *config.Config, api ClusterDirectorClient, tokens oauth2.TokenSource) *handlers {
+	planKey := make([]byte, 32)
+	if _, err := rand.Read(planKey); err != nil {
+		panic(fmt.Sprintf("could not generate plan key: %v", err))
+	}
+	osLogin := &remote.OSLogin{Tokens: tokens}
+	return &handlers{
+		c:               c,
+		api:             api,
+		locations:       newTTLCache[string, map[string][]string](locationsTTL),
+		clusterLists:    newTTLCache[regionTarget, []Cluster](clusterListTTL),
+		clusters:        newTTLCache[string, Cluster](clusterTTL),
+		fleetPages:      newTTLCache[string, FleetListing](fleetSnapshotTTL),
+		planKey:         planKey,
+		pollInterval:    defaultOperationPollInterval,
+		fanOut:          defaultFanOut,
+		slurmBackendFor: newTTLCache[string, string](0),
+		loginNodeFor:    newTTLCache[string, string](0),
+		tokens:          tokens,
+		osLogin:         osLogin,
+		runRemote:       newSSHRunner(tokens, osLogin),
+	}
+}
+
+// Install registers the cluster tools on s, backed by the Cluster Director
+// REST API at c.GetAPIEndpoint(). Without credentials the tools are still
+// registered, and report the missing credentials when called.
 func Install(s *server.MCPServer, c *config.Config) {
-	h := &handlers{
-		c: c,
+	tokens, err := auth.NewTokenSource(context.Background(), c.GetCredentialsFile())
+	if err != nil {
+		slog.Warn("No Google Cloud credentials", "error", err)
+		tokens = auth.Unavailable(err)
 	}
+	// use_profile switches the credentials along with the defaults.
+	tokens = auth.NewSwitchableTokenSource(tokens)
+	InstallWithClient(s, c, NewHTTPClient(c.GetAPIEndpoint(), tokens), tokens)
+}
 
-	// sets authToken
-	getGCloudToken()
+// InstallWithClient registers the cluster tools on s, backed by api. tokens
+// authenticate the connections to login nodes. Tests and local stand-in
+// servers use it to replace the real API. Tools missing from the tool
+// allowlist of c are left out.
+func InstallWithClient(s *server.MCPServer, c *config.Config, api ClusterDirectorClient, tokens oauth2.TokenSource) {
+	h := newHandlers(c, api, tokens)
+	h.server = s
 
 	// HCS does NOT support ALL regions and has an API to return the list of
 	// regions it supports. Use HCS' API instead of GCE API to get ALL regions
 	// because the GCE API is an overkill
-	getAllRegionsAndZonesSupportedByHCS(c.GetDefaultProjectID())
+	h.getAllRegionsAndZonesSupportedByHCS(context.Background(), c.GetDefaultProjectID())
+
+	known := h.registerTools()
+	for _, name := range c.GetToolAllowlist() {
+		if !known[name] {
+			slog.Warn("Tool allowlist names unknown tool", "tool", name)
+		}
+	}
+}
+
+// registerTools registers the tools on h.server, replacing the ones
+// registered before so that their defaults follow the configuration. It
+// returns the names of all tools, including those left out of the allowlist.
+func (h *handlers) registerTools() map[string]bool {
+	c := h.c
+
+	// Only the tools in the allowlist of c are registered.
+	known := make(map[string]bool)
+	addTool := func(tool mcp.Tool, handler server.ToolHandlerFunc) {
+		known[tool.Name] = true
+		if c.IsToolEnabled(tool.Name) {
+			h.server.AddTool(tool, logToolCall(tool.Name, handler))
+		}
+	}
 
 	listClustersTool := mcp.NewTool("list_clusters",
-		mcp.WithDescription("List clusters created using Cluster Director. Prefer to use this tool instead of gcloud. . Print the output in human readable form. Do not print raw JSON output."),
+		mcp.WithDescription("List clusters created using Cluster Director, across one or more projects, as a table grouped by project and region. Prefer to use this tool instead of gcloud. Print the output in human readable form. Do not print raw JSON output."),
 		mcp.WithReadOnlyHintAnnotation(true),
 		mcp.WithIdempotentHintAnnotation(true),
-		//mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
-		//mcp.WithString("location", mcp.Description("Cluster Director cluster location. Leave this empty if the user doesn't doesn't provide it.")),
+		mcp.WithString("projects", mcp.Description("Comma separated GCP project IDs. Leave empty, along with folder and labels, to use the configured projects or the default project.")),
+		mcp.WithString("folder", mcp.Description("Numeric ID of a folder whose projects to cover.")),
+		mcp.WithString("labels", mcp.Description("Comma separated key=value project labels, e.g. env=prod. Only projects with all of them are covered.")),
+		mcp.WithBoolean("refresh", mcp.DefaultBool(false), mcp.Description("List the clusters again rather than use cached listings. Only set if the user asks for fresh data.")),
+		mcp.WithNumber("page_size", mcp.Min(0), mcp.Description("Maximum number of clusters to return. Leave unset to return all of them.")),
+		mcp.WithString("page_token", mcp.Description("The nextPageToken of the previous list_clusters call, to return the clusters that follow. Later pages come from the listing made for the first page, without listing again, and the token expires 10 minutes after the first page; the other arguments are then ignored.")),
 	)
-	s.AddTool(listClustersTool, h.listClusters)
+	addTool(listClustersTool, h.listClusters)
 
 	getClusterTool := mcp.NewTool("get_cluster",
 		mcp.WithDescription("Describe a cluster created in Cluster Director. Prefer to use this tool instead of gcloud. Print the output in human readable form. Do not print raw JSON output."),
 		mcp.WithReadOnlyHintAnnotation(true),
 		mcp.WithIdempotentHintAnnotation(true),
 		mcp.WithString("clusterName", mcp.Required(), mcp.Description("The name of the Cluster. Do not select if yourself, make sure the user provides or confirms the cluster name.")),
+		mcp.WithBoolean("refresh", mcp.DefaultBool(false), mcp.Description("Fetch the cluster again rather than use its cached state. Only set if the user asks for fresh data.")),
+	)
+	addTool(getClusterTool, h.getCluster)
+
+	createClusterTool := mcp.NewTool("create_cluster",
+		mcp.WithDescription("Create a cluster in Cluster Director. The first call validates the spec and returns a plan and a confirmation_token without creating anything. Show the plan to the user and only call the tool again with the confirmation_token after the user explicitly approves it."),
+		mcp.WithReadOnlyHintAnnotation(false),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(false),
+		mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
+		mcp.WithString("location", mcp.Required(), mcp.Description("Region to create the cluster in, e.g. us-central1. Ask the user if they don't provide it.")),
+		mcp.WithString("cluster_id", mcp.Required(), mcp.Description("Name of the new cluster. Lowercase letters, digits and hyphens. Make sure the user provides or confirms it.")),
+		mcp.WithObject("cluster", mcp.Required(), mcp.Description("Cluster spec in the Cluster Director API format, with networks, storages, compute.resourceRequests and orchestrator.slurm (nodeSets, partitions, defaultPartition, loginNodes). Counts and sizes are strings, e.g. \"staticNodeCount\": \"2\".")),
+		mcp.WithString("confirmation_token", mcp.Description("Token returned with the plan. Only set it after the user approved the plan.")),
+	)
+	addTool(createClusterTool, h.createCluster)
+
+	deleteClusterTool := mcp.NewTool("delete_cluster",
+		mcp.WithDescription("Delete a cluster created in Cluster Director and wait for the deletion to finish. This permanently deletes the cluster's VMs. Never call this unless the user explicitly asked to delete this cluster."),
+		mcp.WithReadOnlyHintAnnotation(false),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(false),
+		mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
+		mcp.WithString("clusterName", mcp.Required(), mcp.Description("Name of the cluster to delete. Do not select it yourself, the user must provide it.")),
+		mcp.WithString("confirm_cluster_name", mcp.Required(), mcp.Description("The cluster name re-typed by the user to confirm the deletion. Always ask the user to type it, never copy it from clusterName.")),
+		mcp.WithNumber("timeout_minutes", mcp.DefaultNumber(defaultOperationTimeout.Minutes()), mcp.Min(1), mcp.Description("How many whole minutes to wait for the deletion to finish, at least 1.")),
+	)
+	addTool(deleteClusterTool, h.deleteCluster)
+
+	updateNodeSetSizeTool := mcp.NewTool("update_nodeset_size",
+		mcp.WithDescription("Change the number of static nodes in a Slurm node set of a Cluster Director cluster, then wait for the cluster to finish reconciling. Shows the node and accelerator counts before and after. Confirm the new size with the user before calling this."),
+		mcp.WithReadOnlyHintAnnotation(false),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
+		mcp.WithString("clusterName", mcp.Required(), mcp.Description("Cluster name. Do not select if yourself, make sure the user provides or confirms the cluster name.")),
+		mcp.WithString("nodeset_id", mcp.Required(), mcp.Description("ID of the node set to resize, as shown by get_cluster.")),
+		mcp.WithNumber("static_node_count", mcp.Required(), mcp.Min(0), mcp.Description("The new number of static nodes in the node set.")),
+		mcp.WithNumber("timeout_minutes", mcp.DefaultNumber(defaultOperationTimeout.Minutes()), mcp.Min(1), mcp.Description("How many whole minutes to wait for the update to finish, at least 1.")),
+	)
+	addTool(updateNodeSetSizeTool, h.updateNodeSetSize)
+
+	listOperationsTool := mcp.NewTool("list_operations",
+		mcp.WithDescription("List the long-running operations (cluster create, update and delete) in Cluster Director. Prefer to use this tool instead of gcloud. Print the output in human readable form."),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
+		mcp.WithString("location", mcp.Description("Region to list operations in. Leave empty to list operations in all regions.")),
+		mcp.WithString("state", mcp.DefaultString("all"), mcp.Enum("all", "running", "done"), mcp.Description("Only list operations in this state.")),
 	)
-	s.AddTool(getClusterTool, h.getCluster)
+	addTool(listOperationsTool, h.listOperations)
+
+	getOperationTool := mcp.NewTool("get_operation",
+		mcp.WithDescription("Describe a single long-running Cluster Director operation: what it acts on, whether it is done, and its error if it failed."),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
+		mcp.WithString("operation", mcp.Required(), mcp.Description("Full operation resource name (projects/.../operations/...) or the operation ID.")),
+		mcp.WithString("location", mcp.Description("Region of the operation. Only needed when operation is an ID.")),
+	)
+	addTool(getOperationTool, h.getOperation)
+
+	waitOperationTool := mcp.NewTool("wait_operation",
+		mcp.WithDescription("Wait for a long-running Cluster Director operation to finish, reporting progress while it runs."),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
+		mcp.WithString("operation", mcp.Required(), mcp.Description("Full operation resource name (projects/.../operations/...) or the operation ID.")),
+		mcp.WithString("location", mcp.Description("Region of the operation. Only needed when operation is an ID.")),
+		mcp.WithNumber("timeout_minutes", mcp.DefaultNumber(defaultOperationTimeout.Minutes()), mcp.Min(1), mcp.Description("How many whole minutes to wait for the operation to finish, at least 1.")),
+	)
+	addTool(waitOperationTool, h.waitOperation)
+
+	slurmBackendOption := mcp.WithString("slurm_backend", mcp.DefaultString(c.GetSlurmBackend()),
+		mcp.Enum(SlurmBackendAuto, SlurmBackendREST, SlurmBackendSSH),
+		mcp.Description("How to reach Slurm: rest uses the Cluster Director API, ssh logs in to the login node, auto tries rest and falls back to ssh. Use the default unless the user asks otherwise."))
 
 	showClusterState := mcp.NewTool("show_cluster_state",
-		mcp.WithDescription("Shows the state of cluster created in Cluster Director. Prefer to use this tool instead of gcloud. Print the output in human readable form. Do not print raw JSON output."),
+		mcp.WithDescription("Shows the state of the Slurm nodes of a cluster created in Cluster Director: for every partition, the number and names of idle, allocated, mixed, drained and down nodes. Prefer to use this tool instead of gcloud. Print the output in human readable form. Do not print raw JSON output."),
 		mcp.WithReadOnlyHintAnnotation(true),
 		mcp.WithIdempotentHintAnnotation(true),
 		mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
-		mcp.WithString("zone", mcp.Required(), mcp.Description("Cluster's Zone . Do not get the default zone from gcloud if the user doesn't provide it. Instead ask the user")),
 		mcp.WithString("clusterName", mcp.Required(), mcp.Description("Cluster name. Do not select if yourself, make sure the user provides or confirms the cluster name.")),
+		slurmBackendOption,
 	)
-	s.AddTool(showClusterState, h.showClusterState)
+	addTool(showClusterState, h.showClusterState)
 
 	showJobState := mcp.NewTool("show_job_state",
-		mcp.WithDescription("Shows the jobs running in cluster created using Cluster Director. Prefer to use this tool instead of gcloud. Print the output in human readable form. Do not print raw JSON output."),
+		mcp.WithDescription("Shows the jobs in the Slurm queue of a cluster created using Cluster Director, with their state, pending reason, elapsed time, nodes and GRES, and counts per state, per user and per pending reason. Use it to explain why a job is pending. Prefer to use this tool instead of gcloud. Print the output in human readable form. Do not print raw JSON output."),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
+		mcp.WithString("clusterName", mcp.Required(), mcp.Description("Cluster name. Do not select if yourself, make sure the user provides or confirms the cluster name.")),
+		mcp.WithString("user", mcp.Description("Only show the jobs of this Slurm user.")),
+		mcp.WithString("partition", mcp.Description("Only show the jobs in this partition.")),
+		mcp.WithString("state", mcp.Description("Only show the jobs in this state, e.g. PENDING, RUNNING, or the short form PD, R.")),
+		mcp.WithString("job_name", mcp.Description("Only show the jobs whose name contains this.")),
+		mcp.WithNumber("max_results", mcp.DefaultNumber(defaultMaxJobs), mcp.Min(1), mcp.Description("Maximum number of jobs to list. The counts cover all matching jobs.")),
+		slurmBackendOption,
+	)
+	addTool(showJobState, h.showJobState)
+
+	showPartitions := mcp.NewTool("show_partitions",
+		mcp.WithDescription("Shows the Slurm partitions of a cluster created using Cluster Director, with their nodes and limits. Print the output in human readable form. Do not print raw JSON output."),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
+		mcp.WithString("clusterName", mcp.Required(), mcp.Description("Cluster name. Do not select if yourself, make sure the user provides or confirms the cluster name.")),
+		slurmBackendOption,
+	)
+	addTool(showPartitions, h.showPartitions)
+
+	showReservations := mcp.NewTool("show_reservations",
+		mcp.WithDescription("Shows the Slurm reservations of a cluster created using Cluster Director. Print the output in human readable form. Do not print raw JSON output."),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
+		mcp.WithString("clusterName", mcp.Required(), mcp.Description("Cluster name. Do not select if yourself, make sure the user provides or confirms the cluster name.")),
+		slurmBackendOption,
+	)
+	addTool(showReservations, h.showReservations)
+
+	submitJobTool := mcp.NewTool("submit_job",
+		mcp.WithDescription("Submit a Slurm batch job with sbatch to a cluster created using Cluster Director and return its job ID. The job is checked against the partitions and node sets of the cluster first. Confirm the script and resources with the user before calling this."),
+		mcp.WithReadOnlyHintAnnotation(false),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(false),
+		mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
+		mcp.WithString("clusterName", mcp.Required(), mcp.Description("Cluster name. Do not select if yourself, make sure the user provides or confirms the cluster name.")),
+		mcp.WithString("script", mcp.Description("Body of the batch script, starting with #!. It is uploaded to the login node. Set either script or script_path.")),
+		mcp.WithString("script_path", mcp.Description("Absolute path of a batch script on the cluster's shared storage. Set either script or script_path.")),
+		mcp.WithString("partition", mcp.Description("Partition to run in. Leave empty to use the cluster's default partition.")),
+		mcp.WithNumber("nodes", mcp.DefaultNumber(1), mcp.Min(1), mcp.Description("Number of nodes.")),
+		mcp.WithNumber("gpus_per_node", mcp.DefaultNumber(0), mcp.Min(0), mcp.Description("Number of GPUs per node.")),
+		mcp.WithString("time_limit", mcp.Description("Time limit in sbatch --time format, e.g. 30, 4:00:00 or 1-00:00:00. Leave empty for the partition default.")),
+		mcp.WithString("job_name", mcp.Description("Name of the job.")),
+		mcp.WithString("working_directory", mcp.Description("Absolute path the job runs in. Defaults to the home directory over ssh and /tmp over rest.")),
+		slurmBackendOption,
+	)
+	addTool(submitJobTool, h.submitJob)
+
+	jobHistoryTool := mcp.NewTool("job_history",
+		mcp.WithDescription("Shows the jobs that ran on a cluster created using Cluster Director over a time window, from Slurm accounting (sacct): state, elapsed time, exit code, MaxRSS, allocated TRES and GPU-hours, with a summary of failures and GPU-hours per user. Print the output in human readable form. Do not print raw JSON output."),
 		mcp.WithReadOnlyHintAnnotation(true),
 		mcp.WithIdempotentHintAnnotation(true),
 		mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
-		mcp.WithString("zone", mcp.Required(), mcp.Description("Cluster's Zone . Do not get the default zone from gcloud if the user doesn't provide it. Instead ask the user")),
 		mcp.WithString("clusterName", mcp.Required(), mcp.Description("Cluster name. Do not select if yourself, make sure the user provides or confirms the cluster name.")),
+		mcp.WithString("since", mcp.DefaultString("now-1days"), mcp.Description("Start of the time window in sacct format, e.g. now-1days, 2025-06-30 or 2025-06-30T08:00.")),
+		mcp.WithString("until", mcp.DefaultString("now"), mcp.Description("End of the time window in sacct format.")),
+		mcp.WithString("user", mcp.Description("Only show the jobs of this Slurm user.")),
+		mcp.WithString("partition", mcp.Description("Only show the jobs in this partition.")),
+		mcp.WithString("state", mcp.Description("Only show the jobs in this state, e.g. FAILED, TIMEOUT or COMPLETED.")),
+		mcp.WithNumber("max_results", mcp.DefaultNumber(defaultMaxJobs), mcp.Min(1), mcp.Description("Maximum number of jobs to list. The summary covers all matching jobs.")),
+		slurmBackendOption,
+	)
+	addTool(jobHistoryTool, h.jobHistory)
+
+	for _, jc := range []struct {
+		action      string
+		description string
+		idempotent  bool
+	}{
+		{JobActionCancel, "Cancel a Slurm job (scancel) on a cluster created using Cluster Director. The job is killed and cannot be resumed.", true},
+		{JobActionHold, "Hold a pending Slurm job (scontrol hold) on a cluster created using Cluster Director so that it is not started until released.", true},
+		{JobActionRelease, "Release a held Slurm job (scontrol release) on a cluster created using Cluster Director so that it can be scheduled again.", true},
+		{JobActionRequeue, "Requeue a Slurm job (scontrol requeue) on a cluster created using Cluster Director. A running job is killed and started again later.", false},
+	} {
+		tool := mcp.NewTool(jc.action+"_job",
+			mcp.WithDescription(jc.description+" Reports the state of the job afterwards. Confirm the job with the user before calling this."),
+			mcp.WithReadOnlyHintAnnotation(false),
+			mcp.WithDestructiveHintAnnotation(true),
+			mcp.WithIdempotentHintAnnotation(jc.idempotent),
+			mcp.WithString("project_id", mcp.DefaultString(c.GetDefaultProjectID()), mcp.Description("GCP project ID. Use the default if the user doesn't provide it.")),
+			mcp.WithString("clusterName", mcp.Required(), mcp.Description("Cluster name. Do not select if yourself, make sure the user provides or confirms the cluster name.")),
+			mcp.WithString("job_id", mcp.Required(), mcp.Description("Numeric Slurm job ID, as shown by show_job_state.")),
+			mcp.WithBoolean("allow_other_users", mcp.DefaultBool(false), mcp.Description("Allow acting on a job of another Slurm user. Only set it if the user explicitly asked for it.")),
+			slurmBackendOption,
+		)
+		addTool(tool, h.controlJob(jc.action))
+	}
+
+	useProfileTool := mcp.NewTool("use_profile",
+		mcp.WithDescription("Switch the default project, region and zone, and the account the server calls Google Cloud with, to those of a gcloud configuration, e.g. to move between dev and prod. The result tells in credentialsSwitched whether the account could be switched too; when it is false, calls still go out as the previous account. Without a profile, lists the gcloud configurations and the one in use. Switching is only possible when the server runs over stdio for a single user."),
+		mcp.WithReadOnlyHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithString("profile", mcp.Description("Name of the gcloud configuration to use. Leave it empty to list them.")),
 	)
-	s.AddTool(showJobState, h.showJobState)
+	addTool(useProfileTool, h.useProfile)
+	return known
 }
 
 func (h *handlers) listClusters(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	projectID := h.c.GetDefaultProjectID()
-	genericCore.WriteToLog("-------------------listClusters()-------------------")
-	genericCore.WriteToLog("projectId : " + projectID)
+	pageSize := request.GetInt("page_size", 0)
 
-	return mcp.NewToolResultText(getClustersInAllRegions(h.c.GetDefaultProjectID())), nil
-}
+	// Later pages are cut from the listing of the first one, so that they
+	// neither call the API again nor shift when the cache is refreshed.
+	if pageToken := request.GetString("page_token", ""); pageToken != "" {
+		snapshot, after, err := parseFleetPageToken(pageToken)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		listing, _, ok := h.fleetPages.get(snapshot)
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("page_token %q has expired, call list_clusters without it to start again from a fresh listing", pageToken)), nil
+		}
+		if err := listing.paginate(pageSize, &after, snapshot); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return fleetListingResult(listing), nil
+	}
 
-func (h *handlers) getCluster(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	clusterName, err := request.RequireString("clusterName")
+	sel := FleetSelector{
+		Projects: commaList(request.GetString("projects", "")),
+		Folder:   request.GetString("folder", ""),
+		Labels:   commaList(request.GetString("labels", "")),
+	}
+	if sel.empty() {
+		sel = FleetSelector{Projects: h.c.GetProjects(), Folder: h.c.GetFolder(), Labels: h.c.GetProjectLabels()}
+	}
+
+	projects, err := h.resolveProjects(ctx, sel)
 	if err != nil {
+		return h.toolError(err, h.c.GetDefaultProjectID()), nil
+	}
+	refresh := request.GetBool("refresh", false)
+	listing := h.listFleet(ctx, projects, refresh)
+	full, snapshot := listing, newFleetSnapshot()
+	if err := listing.paginate(pageSize, nil, snapshot); err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
-	projectID := h.c.GetDefaultProjectID()
-	genericCore.WriteToLog("-------------------getCluster()-------------------")
-	genericCore.WriteToLog("projectId : " + projectID)
-	genericCore.WriteToLog("clusterName : " + clusterName)
-
-	// If there is information about this cluster, return it
-	if _, ok := clusterNames2JSON[clusterName]; ok {
-		return mcp.NewToolResultText(clusterNames2JSON[clusterName]), nil
-	} else {
-		// If there is no information, fetch it
-		getClustersInAllRegions(h.c.GetDefaultProjectID())
-		if _, ok := clusterNames2JSON[clusterName]; ok {
-			return mcp.NewToolResultText(clusterNames2JSON[clusterName]), nil
-		} else {
-			return mcp.NewToolResultError(err.Error()), nil
-		}
+	if listing.NextPageToken != "" {
+		h.fleetPages.prune()
+		h.fleetPages.set(snapshot, full)
 	}
+	return fleetListingResult(listing), nil
 }
 
-func (h *handlers) showClusterState(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	// Not implement, for now just call listClusters
-	//return (h.listClusters(ctx, request))
-	projectID := request.GetString("project_id", h.c.GetDefaultProjectID())
-	if projectID == "" {
-		return mcp.NewToolResultError("project_id argument not set"), nil
-	}
-	zone, _ := request.RequireString("zone")
-	if zone == "" && lastClusterInfo.Compute.ResourceRequests[0].Zone == "" {
-		return mcp.NewToolResultError("Need the Zone of the project"), nil
+// fleetListingResult renders a page of list_clusters.
+func fleetListingResult(listing FleetListing) *mcp.CallToolResult {
+	out, err := json.MarshalIndent(listing, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
 	}
+	return mcp.NewToolResultText(string(out))
+}
+
+func (h *handlers) getCluster(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	clusterName, err := request.RequireString("clusterName")
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
-	genericCore.WriteToLog("-------------------getCluster()-------------------")
-	genericCore.WriteToLog("projectId : " + projectID)
-	genericCore.WriteToLog("zone : " + zone)
-	genericCore.WriteToLog("clusterName : " + clusterName)
+	projectID := h.c.GetDefaultProjectID()
+	refresh := request.GetBool("refresh", false)
 
-	sshOut, success := runSSHOnNode("cluster0vk-login-001", projectID, zone, "/usr/local/bin/sinfo")
-	if !success {
+	cluster, fetched, err := h.findCluster(ctx, projectID, clusterName, refresh)
+	if err != nil {
+		return h.toolError(err, projectID), nil
+	}
+	clusterJSON, err := json.MarshalIndent(clusterState{Cluster: cluster, CacheAge: cacheAge(fetched)}, "", "  ")
+	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
-
-	//return mcp.NewToolResultText(string(prettyJSON)), nil
-	return mcp.NewToolResultText(sshOut), nil
+	return mcp.NewToolResultText(string(clusterJSON)), nil
 }
 
-func (h *handlers) showJobState(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	// Not implement, for now just call listClusters
-	//return (h.listClusters(ctx, request))
-	projectID := request.GetString("project_id", h.c.GetDefaultProjectID())
-	if projectID == "" {
-		return mcp.NewToolResultError("project_id argument not set"), nil
+// findCluster returns the state of the cluster called clusterName and when
+// it was fetched, looking for it in the listings of all regions of projectID.
+// A cached state is returned unless refresh is set.
+func (h *handlers) findCluster(ctx context.Context, projectID string, clusterName string, refresh bool) (*Cluster, time.Time, error) {
+	// The cluster may be newer than the cached listings.
+	cluster, fetched, failed := h.locateCluster(ctx, projectID, clusterName, false)
+	if cluster == nil {
+		cluster, fetched, failed = h.locateCluster(ctx, projectID, clusterName, true)
 	}
-	zone, _ := request.RequireString("zone")
-	if zone == "" && lastClusterInfo.Compute.ResourceRequests[0].Zone == "" {
-		return mcp.NewToolResultError("Need the Zone of the project"), nil
+	if cluster == nil {
+		if len(failed) > 0 {
+			var regions []string
+			for _, f := range failed {
+				regions = append(regions, f.Region)
+			}
+			return nil, time.Time{}, fmt.Errorf("cluster %s not found in project %s, the clusters of regions %s could not be listed: %s",
+				clusterName, projectID, strings.Join(regions, ", "), failed[0].Error)
+		}
+		return nil, time.Time{}, fmt.Errorf("cluster %s %w in project %s", clusterName, ErrNotFound, projectID)
 	}
-	clusterName, err := request.RequireString("clusterName")
+	if !refresh {
+		if cached, at, ok := h.clusters.get(cluster.Name); ok {
+			return &cached, at, nil
+		}
+	}
+
+	// Fetch the latest state of the cluster rather than the listing snapshot
+	latest, err := h.api.GetCluster(ctx, cluster.Name)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		slog.WarnContext(ctx, "Could not get cluster", "cluster", cluster.Name, "error", err)
+		return cluster, fetched, nil
 	}
-	genericCore.WriteToLog("-------------------getCluster()-------------------")
-	genericCore.WriteToLog("projectId : " + projectID)
-	genericCore.WriteToLog("zone : " + zone)
-	genericCore.WriteToLog("clusterName : " + clusterName)
+	h.clusters.set(cluster.Name, *latest)
+	return latest, time.Now(), nil
+}
 
-	sshOut, success := runSSHOnNode("cluster0vk-login-001", projectID, zone, "/usr/local/bin/squeue")
-	if !success {
-		return mcp.NewToolResultError(err.Error()), nil
+func (h *handlers) showClusterState(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return h.querySlurm(ctx, request, "state", func(b slurmBackend) (interface{}, error) {
+		records, err := b.NodeStates(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newClusterState(records), nil
+	})
+}
+
+func (h *handlers) showJobState(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filter := JobFilter{
+		User:      request.GetString("user", ""),
+		Partition: request.GetString("partition", ""),
+		State:     request.GetString("state", ""),
+		Name:      request.GetString("job_name", ""),
 	}
+	limit := request.GetInt("max_results", defaultMaxJobs)
+	return h.querySlurm(ctx, request, "jobs", func(b slurmBackend) (interface{}, error) {
+		jobs, err := b.Jobs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newJobState(jobs, filter, limit), nil
+	})
+}
+
+func (h *handlers) showPartitions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return h.querySlurm(ctx, request, "partitions", func(b slurmBackend) (interface{}, error) {
+		return b.Partitions(ctx)
+	})
+}
 
-	return mcp.NewToolResultText(sshOut), nil
+func (h *handlers) showReservations(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return h.querySlurm(ctx, request, "reservations", func(b slurmBackend) (interface{}, error) {
+		return b.Reservations(ctx)
+	})
 }
 
 // gcloudListItem represents a single item from the gcloud list command's JSON output.
@@ -228,30 +560,25 @@ func runGcloudListCommand(resource string) ([]string, error) {
 //  user_project: "hypercomp-pa-prod"
 //}') --rpc_creds_file=<(/google/data/ro/projects/gaiamint/bin/get_mint --type=loas --text --endusercreds --scopes=35600) call --globaldb --noremotedb blade:ccfe-prod-us-central1-hypercomputecluster google.internal.cloud.hypercomputecluster.v1internal.HypercomputeCluster.CallSlurm 'name: "projects/cloud-hypercomp-dev/locations/us-central1/clusters/clusterob9", user:"google", method:"GET", path: "/slurm/v0.0.42/nodes/", body_json: ""'
 
-func runSSHOnNode(hostName string, project string, zone string, cmd string) (string, bool) {
-
-	slurmCmd := "'" + cmd + "'"
-	// Prepare the command
-	finalSSHCmd := exec.Command("/usr/bin/gcloud",
-		"compute",
-		"ssh",
-		hostName,
-		"--project="+project,
-		"--zone="+zone,
-		"--tunnel-through-iap",
-		"--command",
-		slurmCmd)
-
-	// Run the command and capture its output
-	output, err := finalSSHCmd.Output()
-	if err != nil {
-		// If 'gcloud' is not installed or not in the PATH, this will fail.
-		// It can also fail if the user is not authenticated.
-		genericCore.WriteToLog(fmt.Sprintf("Error running SSH: %v", err))
-		return "", false
-	}
-	sshOutput := strings.TrimSpace(string(output))
+// newSSHRunner returns the runRemote of the ssh Slurm backend. Commands are
+// checked against slurmCommands and run over an in-process SSH connection,
+// tunnelled through IAP and authenticated with a key registered with the OS
+// Login profile of the user of tokens by osLogin. Login nodes must present one
+// of the host keys published in their guest attributes.
+func newSSHRunner(tokens oauth2.TokenSource, osLogin *remote.OSLogin) func(ctx context.Context, node remote.Node, cmd remote.Command) (string, error) {
+	runner := remote.NewRunner(&remote.IAPDialer{Tokens: tokens}, osLogin)
+	runner.SetHostKeySource(&remote.GuestAttributes{Tokens: tokens})
+	executor := remote.NewExecutor(runner, slurmCommands)
+	return func(ctx context.Context, node remote.Node, cmd remote.Command) (string, error) {
+		slog.DebugContext(ctx, "Running command over SSH", "command", cmd.String(), "node", node.Instance)
+		output, err := executor.Run(ctx, node, cmd)
+		if err != nil {
+			slog.WarnContext(ctx, "Could not run command over SSH", "command", cmd.String(), "node", node.Instance, "error", err)
+			return "", err
+		}
+		sshOutput := strings.TrimSpace(output)
 
-	genericCore.WriteToLog(string(sshOutput))
-	return sshOutput, true
+		slog.DebugContext(ctx, "Ran command over SSH", "command", cmd.String(), "node", node.Instance, "output", sshOutput)
+		return sshOutput, nil
+	}
 }
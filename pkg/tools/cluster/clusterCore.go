@@ -2,39 +2,22 @@ package cluster
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"os/exec"
-	"path/filepath"
-	"strings"
-
-	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
-	compute "google.golang.org/api/compute/v0.alpha"
+	"log/slog"
+	"sort"
+	"time"
 )
 
-var authToken string
-var regions2Zones = make(map[string][]string)
-
-// *********************************
-// This command works
-// *********************************
-// gcloud compute ssh cluster0vk-login-001 --project=hpc-toolkit-dev --zone=us-central1-c --tunnel-through-iap --command 'sinfo'
-var lastClusterInfo Cluster
-
 // The root struct that holds the list of clusters.
 type ClustersResponse struct {
 	Clusters []Cluster `json:"clusters"`
 }
 
-// var region2Clusters = make(map[string][]Cluster)
-var region2ClusterNames = make(map[string][]string)
-var clusterNames2JSON = make(map[string]string)
-
 // Cluster defines the top-level structure of the JSON object.
 type Cluster struct {
-	Name         string       `json:"name"`
-	CreateTime   string       `json:"createTime"`
-	UpdateTime   string       `json:"updateTime"`
+	Name         string       `json:"name,omitempty"`
+	CreateTime   string       `json:"createTime,omitempty"`
+	UpdateTime   string       `json:"updateTime,omitempty"`
 	Networks     []Network    `json:"networks"`
 	Storages     []Storage    `json:"storages"`
 	Compute      Compute      `json:"compute"`
@@ -106,11 +89,12 @@ type Slurm struct {
 
 // NodeSet corresponds to an object in the "nodeSets" array.
 type NodeSet struct {
-	ID                string          `json:"id"`
-	ResourceRequestID string          `json:"resourceRequestId"`
-	StorageConfigs    []StorageConfig `json:"storageConfigs"`
-	StaticNodeCount   string          `json:"staticNodeCount"`
-	EnableOsLogin     bool            `json:"enableOsLogin"`
+	ID                   string          `json:"id"`
+	ResourceRequestID    string          `json:"resourceRequestId"`
+	StorageConfigs       []StorageConfig `json:"storageConfigs"`
+	StaticNodeCount      string          `json:"staticNodeCount"`
+	AllowAutomaticUpdate bool            `json:"allowAutomaticUpdate,omitempty"`
+	EnableOsLogin        bool            `json:"enableOsLogin"`
 }
 
 // Partition corresponds to an object in the "partitions" array.
@@ -121,16 +105,20 @@ type Partition struct {
 
 // LoginNodes corresponds to the "loginNodes" object.
 type LoginNodes struct {
-	MachineType     string `json:"machineType"`
-	Zone            string `json:"zone"`
-	Count           string `json:"count"`
-	Disks           []Disk `json:"disks"`
-	EnableOsLogin   bool   `json:"enableOsLogin"`
-	EnablePublicIps bool   `json:"enablePublicIps"`
-	Instances       []struct {
-		Instance string `json:"instance"`
-	} `json:"instances"`
-	StorageConfigs []StorageConfig `json:"storageConfigs"`
+	MachineType     string          `json:"machineType"`
+	Zone            string          `json:"zone"`
+	Count           string          `json:"count"`
+	Disks           []Disk          `json:"disks"`
+	EnableOsLogin   bool            `json:"enableOsLogin"`
+	EnablePublicIps bool            `json:"enablePublicIps"`
+	Instances       []LoginInstance `json:"instances"`
+	StorageConfigs  []StorageConfig `json:"storageConfigs"`
+}
+
+// LoginInstance corresponds to an object in the "instances" array of the
+// login nodes.
+type LoginInstance struct {
+	Instance string `json:"instance"`
 }
 
 // StorageConfig corresponds to a storage configuration object.
@@ -139,159 +127,100 @@ type StorageConfig struct {
 	LocalMount string `json:"localMount"`
 }
 
-// getGCloudToken executes the 'gcloud auth print-access-token' command
-// and returns the access token as a string.
-func getGCloudToken() bool {
+// getAllRegionsAndZonesSupportedByHCS records the regions Cluster Director
+// supports in projectID, together with the zones of each region.
+func (h *handlers) getAllRegionsAndZonesSupportedByHCS(ctx context.Context, projectID string) bool {
+	_, _, err := h.regionsAndZones(ctx, projectID, true)
+	return err == nil
+}
 
-	if authToken != "" {
-		return true
+// regionsAndZones returns the regions Cluster Director supports in projectID,
+// mapped to their zones, and when they were fetched. They are served from the
+// cache unless refresh is set.
+func (h *handlers) regionsAndZones(ctx context.Context, projectID string, refresh bool) (map[string][]string, time.Time, error) {
+	if !refresh {
+		if regions, fetched, ok := h.locations.get(projectID); ok {
+			return regions, fetched, nil
+		}
 	}
-
-	genericCore.WriteToLog("Executing 'gcloud auth print-access-token' to get bearer token...")
-
-	// Prepare the command
-	cmd := exec.Command("gcloud", "auth", "print-access-token")
-
-	// Run the command and capture its output
-	output, err := cmd.Output()
+	locations, err := h.api.ListLocations(ctx, projectID)
 	if err != nil {
-		// If 'gcloud' is not installed or not in the PATH, this will fail.
-		// It can also fail if the user is not authenticated.
-		genericCore.WriteToLog(fmt.Sprintf("Error running gcloud command: %v", err))
-		return false
+		slog.WarnContext(ctx, "Could not list the regions supported by Cluster Director", "project", projectID, "error", err)
+		return nil, time.Time{}, fmt.Errorf("could not list the regions Cluster Director supports in project %s: %w", projectID, err)
 	}
 
-	// The output is a byte slice, so we convert it to a string and
-	// trim any trailing newline or whitespace.
-	authToken = strings.TrimSpace(string(output))
-	genericCore.WriteToLog("Successfully retrieved access token.")
-	return true
-}
-
-func getAllZonesInRegion(region string, projectID string, ctx context.Context, computeService *compute.Service) []string {
-	var zonesList []string
-
-	// The filter string tells the API to return only zones whose region name
-	// matches the one we specified.
-	filter := fmt.Sprintf("name=%s-*", region)
-
-	// Call the Zones.List method with the project ID and the filter.
-	req1 := computeService.Zones.List(projectID).Filter(filter)
-
-	// The 'Do' method handles pagination for you. We process each page of results.
-	if err := req1.Pages(ctx, func(page *compute.ZoneList) error {
-		for _, zone := range page.Items {
-			genericCore.WriteToLog(zone.Name)
-			zonesList = append(zonesList, zone.Name)
+	zones := make([][]string, len(locations))
+	errs := fanOut(ctx, len(locations), h.fanOut, func(ctx context.Context, i int) error {
+		var err error
+		zones[i], err = h.api.ListZones(ctx, projectID, locations[i].LocationID)
+		return err
+	})
+	regions := make(map[string][]string, len(locations))
+	for i, loc := range locations {
+		slog.DebugContext(ctx, "Listing zones", "project", projectID, "region", loc.LocationID)
+		if errs[i] != nil {
+			// The region is still usable for listing clusters without its zones.
+			slog.WarnContext(ctx, "Could not list zones", "project", projectID, "region", loc.LocationID, "error", errs[i])
 		}
-		return nil
-	}); err != nil {
-		genericCore.WriteToLog(fmt.Sprintf("Error getting zones for project %s in region %s : %v",
-			projectID, region, err))
+		regions[loc.LocationID] = zones[i]
 	}
-	return zonesList
+	h.locations.set(projectID, regions)
+	return regions, time.Now(), nil
 }
 
-func getAllRegionsAndZonesSupportedByHCS(projectID string) bool {
-	// // Location represents a single location object inside the array.
-	type Location struct {
-		Name       string `json:"name"`
-		LocationID string `json:"locationId"`
-	}
-
-	// LocationList represents the top-level JSON object.
-	type LocationList struct {
-		Locations []Location `json:"locations"`
+// sortedRegions returns the regions of a regionsAndZones map in order.
+func sortedRegions(regions map[string][]string) []string {
+	sorted := make([]string, 0, len(regions))
+	for region := range regions {
+		sorted = append(sorted, region)
 	}
-
-	// Declare a variable of your top-level struct type
-	var locationData LocationList
-
-	// Equivalent CURL command:
-	// curl \
-	// -H "Content-Type:application/json" \
-	// -H "Authorization: Bearer $(gcloud auth print-access-token)" \
-	// https://hypercomputecluster.googleapis.com/v1alpha/projects/cloud-hypercomp-dev/locations/us-central1/clusters
-	url := "https://hypercomputecluster.googleapis.com/v1alpha/projects/" + projectID + "/locations/"
-	/*
-		$ curl     -H "Content-Type:application/json"     -H "Authorization: Bearer $(gcloud auth print-access-token)"     https://hypercomputecluster.googleapis.com/v1alpha/projects/hpc-toolkit-dev/locations/
-		{
-		"locations": [
-		{
-		"name": "projects/hpc-toolkit-dev/locations/asia-southeast1",
-		"locationId": "asia-southeast1"
-		},
-		{
-		"name": "projects/hpc-toolkit-dev/locations/europe-north1",
-		"locationId": "europe-north1"
-		},
-		{
-		"name": "projects/hpc-toolkit-dev/locations/europe-west1",
-		"locationId": "europe-west1"
-		},
-		{
-		"name": "projects/hpc-toolkit-dev/locations/europe-west4",
-		"locationId": "europe-west4"
-		},
-		{
-		"name": "projects/hpc-toolkit-dev/locations/us-central1",
-		"locationId": "us-central1"
-		},
-		{
-		"name": "projects/hpc-toolkit-dev/locations/us-east1",
-		"locationId": "us-east1"
-		},
-		{
-		"name": "projects/hpc-toolkit-dev/locations/us-east4",
-		"locationId": "us-east4"
-		},
-		{
-		"name": "projects/hpc-toolkit-dev/locations/us-east5",
-		"locationId": "us-east5"
-		},
-		{
-		"name": "projects/hpc-toolkit-dev/locations/us-east7",
-		"locationId": "us-east7"
-		},
-		{
-		"name": "projects/hpc-toolkit-dev/locations/us-west1",
-		"locationId": "us-west1"
-		},
-		{
-		"name": "projects/hpc-toolkit-dev/locations/us-west4",
-		"locationId": "us-west4"
+	sort.Strings(sorted)
+	return sorted
+}
+
+// regionTarget is a region of a project to list the clusters of.
+type regionTarget struct {
+	project string
+	region  string
+}
+
+// listClustersIn returns the clusters of every target and when they were
+// fetched, listing those not cached, or all of them when refresh is set,
+// concurrently. The clusters of targets[i] are at index i, nil when listing
+// them failed, in which case the failure is among the returned errors.
+func (h *handlers) listClustersIn(ctx context.Context, targets []regionTarget, refresh bool) ([][]Cluster, []time.Time, []FleetError) {
+	clusters := make([][]Cluster, len(targets))
+	fetched := make([]time.Time, len(targets))
+	errs := fanOut(ctx, len(targets), h.fanOut, func(ctx context.Context, i int) error {
+		t := targets[i]
+		if !refresh {
+			if cached, at, ok := h.clusterLists.get(t); ok {
+				clusters[i], fetched[i] = cached, at
+				return nil
+			}
 		}
-		]
+		slog.DebugContext(ctx, "Listing clusters", "project", t.project, "region", t.region)
+		found, err := h.api.ListClusters(ctx, t.project, t.region)
+		if err != nil {
+			return err
+		}
+		slog.DebugContext(ctx, "Listed clusters", "project", t.project, "region", t.region, "count", len(found))
+		if found == nil {
+			found = []Cluster{}
+		}
+		now := time.Now()
+		h.clusterLists.setFetched(t, found, now)
+		clusters[i], fetched[i] = found, now
+		return nil
+	})
+	var failed []FleetError
+	for i, err := range errs {
+		if err != nil {
+			slog.WarnContext(ctx, "Could not list clusters", "project", targets[i].project, "region", targets[i].region, "error", err)
+			failed = append(failed, h.fleetError(targets[i].project, targets[i].region, err))
 		}
-	*/
-
-	bodyJson, success := genericCore.QueryURLAndGetResult(authToken, url)
-	if !success {
-		genericCore.WriteToLog("Error getting list of zones supported by Cluster Director")
-		return false
-	}
-
-	// Unmarshal the JSON data into the locationData variable
-	// We pass the JSON string as a byte slice and a pointer to our variable.
-	err := json.Unmarshal([]byte(bodyJson), &locationData)
-	if err != nil {
-		genericCore.WriteToLog(fmt.Sprintf("Error unmarshaling JSON: %v", err))
-		return false
-	}
-
-	ctx := context.Background()
-	computeService, err := compute.NewService(ctx)
-	if err != nil {
-		genericCore.WriteToLog(fmt.Sprintf("Error calling compute.NewSerice() API: %v", err))
-		return false
-	}
-	// Now you can access the data through the struct
-	for _, loc := range locationData.Locations {
-		genericCore.WriteToLog("Region: " + loc.LocationID)
-		regions2Zones[loc.LocationID] = getAllZonesInRegion(loc.LocationID, projectID, ctx, computeService)
 	}
-
-	return true
+	return clusters, fetched, failed
 }
 
 // Cluster flow
@@ -301,522 +230,26 @@ func getAllRegionsAndZonesSupportedByHCS(projectID string) bool {
 //   - Get zone, region and any other meta data and store it in a cache to be used as default later
 //   -
 
-func getClustersInAllRegions(projectID string) string {
-	var listOfClusters string = "["
-	for region, _ := range regions2Zones {
-		getClustersInRegionIfExists(region, projectID)
-		for _, clusterName := range region2ClusterNames[region] {
-			listOfClusters += string("\"" + clusterName + "\", ")
-		}
+// locateCluster looks for the cluster called clusterName in the listings of
+// every region of projectID, and returns it as listed and when it was listed.
+// It returns nil, and the regions that could not be listed, when the cluster
+// is not found.
+func (h *handlers) locateCluster(ctx context.Context, projectID string, clusterName string, refresh bool) (*Cluster, time.Time, []FleetError) {
+	regions, _, err := h.regionsAndZones(ctx, projectID, false)
+	if err != nil {
+		return nil, time.Time{}, []FleetError{h.fleetError(projectID, "", err)}
 	}
-	listOfClusters = strings.TrimSuffix(listOfClusters, ", ")
-	listOfClusters += "]"
-	genericCore.WriteToLog("listOfClusters: " + listOfClusters)
-	return listOfClusters
-}
-
-func getClustersInRegionIfExists(region string, projectID string) {
-	// Equivalent CURL command:
-	// curl \
-	// -H "Content-Type:application/json" \
-	// -H "Authorization: Bearer $(gcloud auth print-access-token)" \
-	// https://hypercomputecluster.googleapis.com/v1alpha/projects/cloud-hypercomp-dev/locations/us-central1/clusters
-	url := "https://hypercomputecluster.googleapis.com/v1alpha/projects/" + projectID + "/locations/" + region + "/clusters"
-	/*
-	   	$ curl \
-	       -H "Content-Type:application/json" \
-	       -H "Authorization: Bearer $(gcloud auth print-access-token)" \
-	       https://hypercomputecluster.googleapis.com/v1alpha/projects/hpc-toolkit-dev/locations/us-central1/clusters
-	   {
-	     "clusters": [
-	       {
-	         "name": "projects/hpc-toolkit-dev/locations/us-central1/clusters/quadrant",
-	         "createTime": "2025-07-29T18:11:10.750875543Z",
-	         "updateTime": "2025-07-29T18:26:11.691043831Z",
-	         "networks": [
-	           {
-	             "network": "projects/hpc-toolkit-dev/global/networks/quadrant-net",
-	             "initializeParams": {
-	               "network": "projects/hpc-toolkit-dev/global/networks/quadrant-net"
-	             },
-	             "subnetwork": "projects/hpc-toolkit-dev/global/networks/quadrant-net"
-	           }
-	         ],
-	         "storages": [
-	           {
-	             "storage": "projects/hpc-toolkit-dev/locations/us-central1-c/instances/quadrant-fs",
-	             "initializeParams": {
-	               "filestore": {
-	                 "fileShares": [
-	                   {
-	                     "capacityGb": "1024",
-	                     "fileShare": "nfsshare"
-	                   }
-	                 ],
-	                 "tier": "TIER_ZONAL",
-	                 "filestore": "projects/hpc-toolkit-dev/locations/us-central1-c/instances/quadrant-fs",
-	                 "protocol": "PROTOCOL_NFSV3"
-	               }
-	             },
-	             "id": "home"
-	           },
-	           {
-	             "storage": "projects/hpc-toolkit-dev/locations/us-central1-c/instances/quadrant-fs-1",
-	             "initializeParams": {
-	               "filestore": {
-	                 "fileShares": [
-	                   {
-	                     "capacityGb": "1024",
-	                     "fileShare": "nfsshare"
-	                   }
-	                 ],
-	                 "tier": "TIER_ZONAL",
-	                 "filestore": "projects/hpc-toolkit-dev/locations/us-central1-c/instances/quadrant-fs-1",
-	                 "protocol": "PROTOCOL_NFSV3"
-	               }
-	             },
-	             "id": "shared0"
-	           }
-	         ],
-	         "compute": {
-	           "resourceRequests": [
-	             {
-	               "id": "quadrant-rr1",
-	               "zone": "us-central1-c",
-	               "machineType": "n2-standard-2",
-	               "guestAccelerators": [
-	                 {}
-	               ],
-	               "disks": [
-	                 {
-	                   "type": "pd-balanced",
-	                   "sizeGb": "100",
-	                   "boot": true,
-	                   "sourceImage": "projects/hpc-toolkit-dev/global/images/family/common-slurm-image"
-	                 }
-	               ],
-	               "provisioningModel": "PROVISIONING_MODEL_STANDARD"
-	             }
-	           ]
-	         },
-	         "orchestrator": {
-	           "slurm": {
-	             "nodeSets": [
-	               {
-	                 "id": "nodeset1",
-	                 "resourceRequestId": "quadrant-rr1",
-	                 "storageConfigs": [
-	                   {
-	                     "id": "home",
-	                     "localMount": "/home"
-	                   },
-	                   {
-	                     "id": "shared0",
-	                     "localMount": "/shared0"
-	                   }
-	                 ],
-	                 "staticNodeCount": "1",
-	                 "allowAutomaticUpdate": true,
-	                 "enableOsLogin": true
-	               }
-	             ],
-	             "partitions": [
-	               {
-	                 "id": "part1",
-	                 "nodeSetIds": [
-	                   "nodeset1"
-	                 ]
-	               }
-	             ],
-	             "defaultPartition": "part1",
-	             "loginNodes": {
-	               "machineType": "n2-standard-2",
-	               "zone": "us-central1-c",
-	               "count": "1",
-	               "disks": [
-	                 {
-	                   "type": "pd-balanced",
-	                   "sizeGb": "100",
-	                   "boot": true,
-	                   "sourceImage": "projects/hpc-toolkit-dev/global/images/family/common-slurm-image"
-	                 }
-	               ],
-	               "enableOsLogin": true,
-	               "enablePublicIps": true,
-	               "instances": [
-	                 {
-	                   "instance": "projects/hpc-toolkit-dev/zones/us-central1-c/instances/quadrant-login-001"
-	                 }
-	               ],
-	               "storageConfigs": [
-	                 {
-	                   "id": "home",
-	                   "localMount": "/home"
-	                 },
-	                 {
-	                   "id": "shared0",
-	                   "localMount": "/shared0"
-	                 }
-	               ]
-	             }
-	           }
-	         },
-	         "reconciling": false
-	       },
-	       {
-	         "name": "projects/hpc-toolkit-dev/locations/us-central1/clusters/clusterum7",
-	         "createTime": "2025-07-28T17:18:00.818807445Z",
-	         "updateTime": "2025-07-28T17:33:08.231509624Z",
-	         "networks": [
-	           {
-	             "network": "projects/hpc-toolkit-dev/global/networks/clusterum7-net",
-	             "initializeParams": {
-	               "network": "projects/hpc-toolkit-dev/global/networks/clusterum7-net"
-	             },
-	             "subnetwork": "projects/hpc-toolkit-dev/global/networks/clusterum7-net"
-	           }
-	         ],
-	         "storages": [
-	           {
-	             "storage": "projects/hpc-toolkit-dev/locations/us-central1-c/instances/clusterum7-fs",
-	             "initializeParams": {
-	               "filestore": {
-	                 "fileShares": [
-	                   {
-	                     "capacityGb": "1024",
-	                     "fileShare": "nfsshare"
-	                   }
-	                 ],
-	                 "tier": "TIER_ZONAL",
-	                 "filestore": "projects/hpc-toolkit-dev/locations/us-central1-c/instances/clusterum7-fs",
-	                 "protocol": "PROTOCOL_NFSV3"
-	               }
-	             },
-	             "id": "home"
-	           }
-	         ],
-	         "compute": {
-	           "resourceRequests": [
-	             {
-	               "id": "cjdcluster1",
-	               "zone": "us-central1-c",
-	               "machineType": "n2-standard-2",
-	               "guestAccelerators": [
-	                 {}
-	               ],
-	               "disks": [
-	                 {
-	                   "type": "pd-balanced",
-	                   "sizeGb": "100",
-	                   "boot": true,
-	                   "sourceImage": "projects/hpc-toolkit-dev/global/images/family/common-slurm-image"
-	                 }
-	               ],
-	               "provisioningModel": "PROVISIONING_MODEL_STANDARD"
-	             }
-	           ]
-	         },
-	         "orchestrator": {
-	           "slurm": {
-	             "nodeSets": [
-	               {
-	                 "id": "nodeset1",
-	                 "resourceRequestId": "cjdcluster1",
-	                 "storageConfigs": [
-	                   {
-	                     "id": "home",
-	                     "localMount": "/home"
-	                   }
-	                 ],
-	                 "staticNodeCount": "6",
-	                 "enableOsLogin": true
-	               }
-	             ],
-	             "partitions": [
-	               {
-	                 "id": "part1",
-	                 "nodeSetIds": [
-	                   "nodeset1"
-	                 ]
-	               }
-	             ],
-	             "defaultPartition": "part1",
-	             "loginNodes": {
-	               "machineType": "n2-standard-2",
-	               "zone": "us-central1-c",
-	               "count": "1",
-	               "disks": [
-	                 {
-	                   "type": "pd-balanced",
-	                   "sizeGb": "100",
-	                   "boot": true,
-	                   "sourceImage": "projects/hpc-toolkit-dev/global/images/family/common-slurm-image"
-	                 }
-	               ],
-	               "enableOsLogin": true,
-	               "enablePublicIps": true,
-	               "instances": [
-	                 {
-	                   "instance": "projects/hpc-toolkit-dev/zones/us-central1-c/instances/clusterum7-login-001"
-	                 }
-	               ],
-	               "storageConfigs": [
-	                 {
-	                   "id": "home",
-	                   "localMount": "/home"
-	                 }
-	               ]
-	             }
-	           }
-	         },
-	         "reconciling": false
-	       },
-	       {
-	         "name": "projects/hpc-toolkit-dev/locations/us-central1/clusters/cluster0vk",
-	         "createTime": "2025-07-30T08:26:31.572323371Z",
-	         "updateTime": "2025-07-30T08:40:05.631214829Z",
-	         "networks": [
-	           {
-	             "network": "projects/hpc-toolkit-dev/global/networks/cluster0vk-net",
-	             "initializeParams": {
-	               "network": "projects/hpc-toolkit-dev/global/networks/cluster0vk-net"
-	             },
-	             "subnetwork": "projects/hpc-toolkit-dev/global/networks/cluster0vk-net"
-	           }
-	         ],
-	         "storages": [
-	           {
-	             "storage": "projects/hpc-toolkit-dev/locations/us-central1-c/instances/cluster0vk-fs",
-	             "initializeParams": {
-	               "filestore": {
-	                 "fileShares": [
-	                   {
-	                     "capacityGb": "1024",
-	                     "fileShare": "nfsshare"
-	                   }
-	                 ],
-	                 "tier": "TIER_ZONAL",
-	                 "filestore": "projects/hpc-toolkit-dev/locations/us-central1-c/instances/cluster0vk-fs",
-	                 "protocol": "PROTOCOL_NFSV3"
-	               }
-	             },
-	             "id": "home"
-	           }
-	         ],
-	         "compute": {
-	           "resourceRequests": [
-	             {
-	               "id": "cluster0vk-rr1",
-	               "zone": "us-central1-c",
-	               "machineType": "n2-standard-2",
-	               "guestAccelerators": [
-	                 {}
-	               ],
-	               "disks": [
-	                 {
-	                   "type": "pd-balanced",
-	                   "sizeGb": "100",
-	                   "boot": true,
-	                   "sourceImage": "projects/hpc-toolkit-dev/global/images/family/common-slurm-image"
-	                 }
-	               ],
-	               "provisioningModel": "PROVISIONING_MODEL_STANDARD"
-	             }
-	           ]
-	         },
-	         "orchestrator": {
-	           "slurm": {
-	             "nodeSets": [
-	               {
-	                 "id": "nodeset1",
-	                 "resourceRequestId": "cluster0vk-rr1",
-	                 "storageConfigs": [
-	                   {
-	                     "id": "home",
-	                     "localMount": "/home"
-	                   }
-	                 ],
-	                 "staticNodeCount": "2",
-	                 "enableOsLogin": true
-	               }
-	             ],
-	             "partitions": [
-	               {
-	                 "id": "part1",
-	                 "nodeSetIds": [
-	                   "nodeset1"
-	                 ]
-	               }
-	             ],
-	             "defaultPartition": "part1",
-	             "loginNodes": {
-	               "machineType": "n2-standard-2",
-	               "zone": "us-central1-c",
-	               "count": "1",
-	               "disks": [
-	                 {
-	                   "type": "pd-balanced",
-	                   "sizeGb": "100",
-	                   "boot": true,
-	                   "sourceImage": "projects/hpc-toolkit-dev/global/images/family/common-slurm-image"
-	                 }
-	               ],
-	               "enableOsLogin": true,
-	               "enablePublicIps": true,
-	               "instances": [
-	                 {
-	                   "instance": "projects/hpc-toolkit-dev/zones/us-central1-c/instances/cluster0vk-login-001"
-	                 }
-	               ],
-	               "storageConfigs": [
-	                 {
-	                   "id": "home",
-	                   "localMount": "/home"
-	                 }
-	               ]
-	             }
-	           }
-	         },
-	         "reconciling": false
-	       },
-	       {
-	         "name": "projects/hpc-toolkit-dev/locations/us-central1/clusters/harsclus",
-	         "createTime": "2025-06-18T20:13:05.824451278Z",
-	         "updateTime": "2025-06-18T20:25:48.817877731Z",
-	         "networks": [
-	           {
-	             "network": "projects/hpc-toolkit-dev/global/networks/harsclus-net",
-	             "initializeParams": {
-	               "network": "projects/hpc-toolkit-dev/global/networks/harsclus-net"
-	             },
-	             "subnetwork": "projects/hpc-toolkit-dev/global/networks/harsclus-net"
-	           }
-	         ],
-	         "storages": [
-	           {
-	             "storage": "projects/hpc-toolkit-dev/locations/us-central1-c/instances/harsclus-fs",
-	             "initializeParams": {
-	               "filestore": {
-	                 "fileShares": [
-	                   {
-	                     "capacityGb": "1024",
-	                     "fileShare": "nfsshare"
-	                   }
-	                 ],
-	                 "tier": "TIER_ZONAL",
-	                 "filestore": "projects/hpc-toolkit-dev/locations/us-central1-c/instances/harsclus-fs",
-	                 "protocol": "PROTOCOL_NFSV3"
-	               }
-	             },
-	             "id": "home"
-	           }
-	         ],
-	         "compute": {
-	           "resourceRequests": [
-	             {
-	               "id": "harsclus-rr1",
-	               "zone": "us-central1-c",
-	               "machineType": "n2-standard-2",
-	               "guestAccelerators": [
-	                 {}
-	               ],
-	               "provisioningModel": "PROVISIONING_MODEL_STANDARD"
-	             }
-	           ]
-	         },
-	         "orchestrator": {
-	           "slurm": {
-	             "nodeSets": [
-	               {
-	                 "id": "nodeset1",
-	                 "resourceRequestId": "harsclus-rr1",
-	                 "storageConfigs": [
-	                   {
-	                     "id": "home",
-	                     "localMount": "/home"
-	                   }
-	                 ],
-	                 "staticNodeCount": "2",
-	                 "allowAutomaticUpdate": true,
-	                 "enableOsLogin": true
-	               }
-	             ],
-	             "partitions": [
-	               {
-	                 "id": "part1",
-	                 "nodeSetIds": [
-	                   "nodeset1"
-	                 ]
-	               }
-	             ],
-	             "defaultPartition": "part1",
-	             "loginNodes": {
-	               "machineType": "n2-standard-2",
-	               "zone": "us-central1-c",
-	               "count": "1",
-	               "disks": [
-	                 {
-	                   "type": "pd-balanced",
-	                   "sizeGb": "100",
-	                   "boot": true
-	                 }
-	               ],
-	               "enableOsLogin": true,
-	               "enablePublicIps": true,
-	               "instances": [
-	                 {
-	                   "instance": "projects/hpc-toolkit-dev/zones/us-central1-c/instances/harsclus-login-001"
-	                 }
-	               ]
-	             }
-	           }
-	         },
-	         "reconciling": false
-	       }
-	     ]
-	   }
-	*/
-	//print("URL: " + url)
-	genericCore.WriteToLog(fmt.Sprintf("Getting clusters in region %s URL : %s", region, url))
-
-	// Remove all previous data about clusters in this region
-	//delete(region2Clusters, region)
-	//delete(region2ClusterNames, region)
-	region2ClusterNames[region] = []string{}
-
-	bodyString, success := genericCore.QueryURLAndGetResult(authToken, url)
-	genericCore.WriteToLog("Body : " + string(bodyString))
-	if success && strings.Contains(bodyString, "storages") {
-		genericCore.WriteToLog("AAAA Found a cluster trying to parse JSON")
-		// If the body has "storages" than that means there is a cluster
-		var parsedClusterData ClustersResponse
-		//err := json.Unmarshal([]byte(bodyString), &parsedClusterData)
-		//region2Clusters[region] = parsedClusterData.Clusters
-		err := json.Unmarshal([]byte(bodyString), &parsedClusterData)
-		genericCore.WriteToLog("BBBB")
-		if err != nil {
-			genericCore.WriteToLog(fmt.Sprintf("CCCC Error unmarshalling JSON: %v", err))
-		} else {
-			//genericCore.WriteToLog(fmt.Sprintf("DDDD.0000 Number of elements in region2Clusters[region]: %d", len(region2Clusters[region])))
-			genericCore.WriteToLog(fmt.Sprintf("DDDD.1111 Number of elements in parsedClusterData.Clusters: %d", len(parsedClusterData.Clusters)))
-			for i, v := range parsedClusterData.Clusters {
-				clusterName := filepath.Base(parsedClusterData.Clusters[i].Name)
-				genericCore.WriteToLog(fmt.Sprintf("EEEE i: %d", i))
-				genericCore.WriteToLog(fmt.Sprintf("FFFF Struct: %v", v))
-				region2ClusterNames[region] = append(region2ClusterNames[region], clusterName)
-				clusterNames2JSON[clusterName] = string(bodyString)
-			}
-
-			genericCore.WriteToLog("GGGG")
-
-			for i, v := range region2ClusterNames[region] {
-				genericCore.WriteToLog(fmt.Sprintf("HHHH i: %d", i))
-				genericCore.WriteToLog(fmt.Sprintf("IIII Struct: %s", v))
+	var targets []regionTarget
+	for _, region := range sortedRegions(regions) {
+		targets = append(targets, regionTarget{project: projectID, region: region})
+	}
+	lists, fetched, failed := h.listClustersIn(ctx, targets, refresh)
+	for i, list := range lists {
+		for _, c := range list {
+			if ShortName(c.Name) == clusterName {
+				return &c, fetched[i], nil
 			}
-			genericCore.WriteToLog("JJJJ")
 		}
-	} else {
-		genericCore.WriteToLog("KKKK The response body does not contain the substring 'storages'.")
 	}
-	genericCore.WriteToLog("LLLL")
+	return nil, time.Time{}, failed
 }
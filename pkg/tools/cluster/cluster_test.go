@@ -0,0 +1,1120 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/oauth2"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/auth"
+	"github.com/nadig-google/cluster-director-mcp/pkg/config"
+	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
+	"github.com/nadig-google/cluster-director-mcp/pkg/remote"
+	"github.com/nadig-google/cluster-director-mcp/pkg/transport"
+)
+
+const testProject = "hpc-toolkit-dev"
+
+var testTokens = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "test-token"})
+
+// loadTestClusters returns the clusters in testdata/clusters.json, a recorded
+// response of the clusters.list API.
+func loadTestClusters(t *testing.T) []Cluster {
+	t.Helper()
+	data, err := os.ReadFile("testdata/clusters.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	var resp ClustersResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("Failed to unmarshal test data: %v", err)
+	}
+	return resp.Clusters
+}
+
+// newTestHandlers returns handlers backed by a FakeClient holding the test
+// clusters.
+func newTestHandlers(t *testing.T) (*handlers, *FakeClient) {
+	t.Helper()
+	fake := NewFakeClient()
+	fake.AddLocation(testProject, "us-central1", "us-central1-a", "us-central1-c")
+	fake.AddLocation(testProject, "europe-west4", "europe-west4-a")
+	for _, c := range loadTestClusters(t) {
+		fake.AddCluster(c)
+	}
+
+	c := &config.Config{}
+	c.SetDefaultProjectID(testProject)
+	h := newHandlers(c, fake, testTokens)
+	if !h.getAllRegionsAndZonesSupportedByHCS(context.Background(), testProject) {
+		t.Fatal("getAllRegionsAndZonesSupportedByHCS() failed")
+	}
+	return h, fake
+}
+
+// fakeLoginNodes makes the ssh Slurm backend of h run the commands of
+// slurmCommands with run, which gets the command line and standard input.
+func fakeLoginNodes(h *handlers, run func(node remote.Node, cmd string, stdin []byte) (string, error)) {
+	h.runRemote = remote.NewExecutor(remote.TransportFunc(func(ctx context.Context, node remote.Node, cmd string, opts remote.RunOptions) (string, error) {
+		return run(node, cmd, opts.Stdin)
+	}), slurmCommands).Run
+}
+
+func callTool(t *testing.T, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), args map[string]any) (string, bool) {
+	t.Helper()
+	var req mcp.CallToolRequest
+	req.Params.Arguments = args
+	res, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Tool handler returned error: %v", err)
+	}
+	var text strings.Builder
+	for _, c := range res.Content {
+		if tc, ok := c.(mcp.TextContent); ok {
+			text.WriteString(tc.Text)
+		}
+	}
+	return text.String(), res.IsError
+}
+
+func TestListClusters(t *testing.T) {
+	h, _ := newTestHandlers(t)
+
+	out, isErr := callTool(t, h.listClusters, nil)
+	if isErr {
+		t.Fatalf("list_clusters failed: %s", out)
+	}
+	for _, name := range []string{"quadrant", "clusterum7", "cluster0vk", "harsclus"} {
+		if !strings.Contains(out, `"`+name+`"`) {
+			t.Errorf("list_clusters output %s does not contain %s", out, name)
+		}
+	}
+}
+
+func TestListClustersFleet(t *testing.T) {
+	h, fake := newTestHandlers(t)
+	fake.AddProject("prod-project-1", "folders/42", map[string]string{"env": "prod"})
+	fake.AddProject("prod-project-2", "folders/42", map[string]string{"env": "prod"})
+	fake.AddProject("dev-project-1", "folders/42", map[string]string{"env": "dev"})
+	fake.AddCluster(Cluster{Name: ClusterResourceName("prod-project-1", "us-east1", "prodclus")})
+	fake.AddCluster(Cluster{Name: ClusterResourceName("dev-project-1", "us-east1", "devclus")})
+
+	decode := func(out string) FleetListing {
+		t.Helper()
+		var listing FleetListing
+		if err := json.Unmarshal([]byte(out), &listing); err != nil {
+			t.Fatalf("list_clusters output %s is not a listing: %v", out, err)
+		}
+		return listing
+	}
+
+	out, isErr := callTool(t, h.listClusters, map[string]any{"projects": testProject, "folder": "42", "labels": "env=prod"})
+	if isErr {
+		t.Fatalf("list_clusters failed: %s", out)
+	}
+	listing := decode(out)
+	if got, want := strings.Join(listing.Projects, ","), "hpc-toolkit-dev,prod-project-1,prod-project-2"; got != want {
+		t.Errorf("list_clusters covered projects %s, want %s", got, want)
+	}
+	var rows []string
+	for _, c := range listing.Clusters {
+		rows = append(rows, c.Project+"/"+c.Region+"/"+c.Name)
+	}
+	if len(rows) < 2 || rows[len(rows)-1] != "prod-project-1/us-east1/prodclus" || !strings.HasPrefix(rows[0], testProject+"/") {
+		t.Errorf("list_clusters rows = %v, want the default project first and prodclus last", rows)
+	}
+	for _, row := range rows {
+		if strings.Contains(row, "devclus") {
+			t.Errorf("list_clusters listed %s outside the selector", row)
+		}
+	}
+
+	// The configured selector is used when the call gives none.
+	h.c.SetProjects([]string{"dev-project-1"})
+	out, _ = callTool(t, h.listClusters, nil)
+	if listing := decode(out); len(listing.Clusters) != 1 || listing.Clusters[0].Name != "devclus" {
+		t.Errorf("list_clusters with configured projects = %+v, want devclus", listing.Clusters)
+	}
+
+	for _, args := range []map[string]any{
+		{"labels": "env=prod labels.x:y"},
+		{"folder": "my-folder"},
+		{"projects": "Not A Project"},
+	} {
+		if out, isErr := callTool(t, h.listClusters, args); !isErr {
+			t.Errorf("list_clusters(%v) = %s, want an error", args, out)
+		}
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	var running, peak atomic.Int32
+	errs := fanOut(context.Background(), 20, 3, func(ctx context.Context, i int) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		if i == 7 {
+			return errors.New("region 7 failed")
+		}
+		return nil
+	})
+	if p := peak.Load(); p > 3 || p < 2 {
+		t.Errorf("fanOut ran %d calls at once, want at most 3", p)
+	}
+	for i, err := range errs {
+		if (err != nil) != (i == 7) {
+			t.Errorf("fanOut error %d = %v", i, err)
+		}
+	}
+
+	// Calls are not started once the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	var started atomic.Int32
+	errs = fanOut(ctx, 10, 1, func(ctx context.Context, i int) error {
+		if started.Add(1) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if n := started.Load(); n != 2 {
+		t.Errorf("fanOut started %d calls after cancellation, want 2", n)
+	}
+	if !errors.Is(errs[9], context.Canceled) {
+		t.Errorf("fanOut error of a call never started = %v, want %v", errs[9], context.Canceled)
+	}
+}
+
+func TestListClustersPartial(t *testing.T) {
+	h, fake := newTestHandlers(t)
+	fake.FailListClusters(testProject, "europe-west4", errors.New("permission denied"))
+
+	out, isErr := callTool(t, h.listClusters, nil)
+	if isErr {
+		t.Fatalf("list_clusters failed: %s", out)
+	}
+	var listing FleetListing
+	if err := json.Unmarshal([]byte(out), &listing); err != nil {
+		t.Fatal(err)
+	}
+	if len(listing.Clusters) == 0 {
+		t.Errorf("list_clusters dropped the regions that could be listed")
+	}
+	if len(listing.Errors) != 1 || listing.Errors[0].Region != "europe-west4" || !strings.Contains(listing.Errors[0].Error, "permission denied") {
+		t.Errorf("list_clusters errors = %+v, want europe-west4 annotated", listing.Errors)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if listing := h.listFleet(ctx, []string{testProject}, true); len(listing.Errors) == 0 || !strings.Contains(listing.Errors[0].Error, "canceled") {
+		t.Errorf("listFleet with a canceled context = %+v, want the regions annotated as canceled", listing.Errors)
+	}
+}
+
+func TestToolAllowlist(t *testing.T) {
+	fake := NewFakeClient()
+	fake.AddLocation(testProject, "us-central1", "us-central1-a")
+	c := &config.Config{}
+	c.SetDefaultProjectID(testProject)
+	c.SetToolAllowlist([]string{"list_clusters", "cancel_job"})
+	s := server.NewMCPServer("test", "0.0.1", server.WithToolCapabilities(true))
+	InstallWithClient(s, c, fake, testTokens)
+
+	resp := s.HandleMessage(context.Background(), []byte(`{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`))
+	res, ok := resp.(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("tools/list returned %#v", resp)
+	}
+	var names []string
+	for _, tool := range res.Result.(mcp.ListToolsResult).Tools {
+		names = append(names, tool.Name)
+	}
+	if got, want := strings.Join(names, ","), "cancel_job,list_clusters"; got != want {
+		t.Errorf("registered tools = %s, want %s", got, want)
+	}
+}
+
+func TestUseProfile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLOUDSDK_CONFIG", dir)
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "dev")
+	os.MkdirAll(filepath.Join(dir, "configurations"), 0o755)
+	os.WriteFile(filepath.Join(dir, "configurations", "config_dev"), []byte("[core]\nproject = "+testProject+"\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "configurations", "config_prod"), []byte("[core]\nproject = prod-project\n[compute]\nregion = europe-west4\n"), 0o600)
+
+	h, _ := newTestHandlers(t)
+	if _, _, err := h.findCluster(context.Background(), testProject, "quadrant", false); err != nil {
+		t.Fatalf("findCluster() failed: %v", err)
+	}
+
+	out, isErr := callTool(t, h.useProfile, nil)
+	if isErr || !strings.Contains(out, `"inUse": "dev"`) || !strings.Contains(out, "prod-project") {
+		t.Errorf("use_profile without a profile = %s, want dev in use and prod listed", out)
+	}
+
+	out, isErr = callTool(t, h.useProfile, map[string]any{"profile": "prod"})
+	if isErr {
+		t.Fatalf("use_profile(prod) failed: %s", out)
+	}
+	if h.c.GetDefaultProjectID() != "prod-project" || h.c.GetDefaultRegion() != "europe-west4" {
+		t.Errorf("use_profile(prod) set project %q and region %q", h.c.GetDefaultProjectID(), h.c.GetDefaultRegion())
+	}
+	if _, _, ok := h.clusters.get(ClusterResourceName(testProject, "us-central1", "quadrant")); ok {
+		t.Errorf("use_profile(prod) kept the clusters of the previous project")
+	}
+
+	if out, isErr := callTool(t, h.useProfile, map[string]any{"profile": "missing"}); !isErr {
+		t.Errorf("use_profile(missing) = %s, want an error", out)
+	}
+
+	// Explicit credentials stay in use, and the result says so.
+	tokens := auth.NewSwitchableTokenSource(testTokens)
+	h.tokens = tokens
+	h.c.SetCredentialsFile("/keys/sa.json")
+	out, isErr = callTool(t, h.useProfile, map[string]any{"profile": "prod"})
+	if isErr || !strings.Contains(out, `"credentialsSwitched": false`) || !strings.Contains(out, "credentials_file /keys/sa.json") {
+		t.Errorf("use_profile(prod) with a credentials_file = %s, want the credentials kept", out)
+	}
+	if token, err := tokens.Token(); err != nil || token.AccessToken != "test-token" {
+		t.Errorf("use_profile(prod) with a credentials_file switched the token to %v, %v", token, err)
+	}
+
+	// Clients of a shared transport may list the profiles, not switch them.
+	h.c.SetTransport(transport.StreamableHTTP)
+	if out, isErr := callTool(t, h.useProfile, map[string]any{"profile": "dev"}); !isErr || h.c.GetDefaultProjectID() != "prod-project" {
+		t.Errorf("use_profile(dev) over %s = %s, want an error", transport.StreamableHTTP, out)
+	}
+	if out, isErr := callTool(t, h.useProfile, nil); isErr {
+		t.Errorf("use_profile without a profile over %s failed: %s", transport.StreamableHTTP, out)
+	}
+}
+
+func TestGetCluster(t *testing.T) {
+	h, _ := newTestHandlers(t)
+
+	out, isErr := callTool(t, h.getCluster, map[string]any{"clusterName": "cluster0vk"})
+	if isErr {
+		t.Fatalf("get_cluster failed: %s", out)
+	}
+	var got Cluster
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("get_cluster returned invalid JSON: %v", err)
+	}
+	if want := ClusterResourceName(testProject, "us-central1", "cluster0vk"); got.Name != want {
+		t.Errorf("get_cluster returned %s, want %s", got.Name, want)
+	}
+
+	out, isErr = callTool(t, h.getCluster, map[string]any{"clusterName": "missing"})
+	if !isErr || !strings.Contains(out, "Kind: NOT_FOUND") {
+		t.Errorf("get_cluster of a missing cluster = %s, want a NOT_FOUND error", out)
+	}
+}
+
+func TestClusterCacheAcrossRegions(t *testing.T) {
+	h, fake := newTestHandlers(t)
+	ctx := context.Background()
+	central := ClusterResourceName(testProject, "us-central1", "quadrant")
+	west := ClusterResourceName(testProject, "europe-west4", "quadrant")
+	fake.AddCluster(Cluster{Name: west})
+
+	first, _, err := h.findCluster(ctx, testProject, "quadrant", true)
+	if err != nil {
+		t.Fatalf("findCluster() failed: %v", err)
+	}
+	other := central
+	if first.Name == central {
+		other = west
+	}
+	h.clusters.set(other, Cluster{Name: other})
+	if again, _, err := h.findCluster(ctx, testProject, "quadrant", false); err != nil || again.Name != first.Name {
+		t.Errorf("findCluster() after caching %s = %v, %v, want %s", other, again, err, first.Name)
+	}
+	h.forgetClusterState(other)
+	if _, _, ok := h.clusters.get(first.Name); !ok {
+		t.Errorf("forgetting %s evicted %s", other, first.Name)
+	}
+}
+
+func TestLogToolCall(t *testing.T) {
+	h, _ := newTestHandlers(t)
+	var buf strings.Builder
+	handler, err := genericCore.NewLogHandler(&buf, genericCore.LogOptions{Level: "debug", Format: genericCore.LogFormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(handler))
+
+	callTool(t, logToolCall("get_cluster", h.getCluster), map[string]any{"clusterName": "missing"})
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("record %s is not JSON: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	last := records[len(records)-1]
+	if last["level"] != "WARN" || last["tool"] != "get_cluster" || last["call_id"] == nil {
+		t.Errorf("outcome of a failed get_cluster logged as %v", last)
+	}
+	for _, record := range records {
+		if record["call_id"] != last["call_id"] {
+			t.Errorf("record %v of the call lacks its call_id %v", record, last["call_id"])
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	h, _ := newTestHandlers(t)
+	url := "https://hypercomputecluster.googleapis.com/v1alpha/projects/other-project/locations/us-central1/clusters"
+	disabled := &genericCore.APIError{URL: url, StatusCode: http.StatusForbidden, Status: "PERMISSION_DENIED",
+		Message: "Cluster Director API has not been used in project 123 before or it is disabled.",
+		Details: []json.RawMessage{json.RawMessage(`{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"SERVICE_DISABLED","metadata":{"service":"hypercomputecluster.googleapis.com"}}`)}}
+	denied := &genericCore.APIError{URL: url, StatusCode: http.StatusForbidden, Status: "PERMISSION_DENIED", Message: "Permission denied",
+		Details: []json.RawMessage{json.RawMessage(`{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"IAM_PERMISSION_DENIED","metadata":{"permission":"hypercomputecluster.clusters.list"}}`)}}
+
+	for _, tc := range []struct {
+		err  error
+		kind ErrorKind
+		hint string
+	}{
+		{fmt.Errorf("could not list clusters: %w", disabled), ErrorAPIDisabled, "gcloud services enable hypercomputecluster.googleapis.com --project=other-project"},
+		{denied, ErrorPermissionDenied, "hypercomputecluster.clusters.list in project other-project"},
+		{&genericCore.APIError{URL: url, StatusCode: http.StatusUnauthorized}, ErrorAuthExpired, "gcloud auth application-default login"},
+		{fmt.Errorf("%w: token expired", auth.ErrNoAccessToken), ErrorAuthExpired, "gcloud auth application-default login"},
+		{fmt.Errorf("login node n: %w", remote.ErrIAPDenied), ErrorIAPDenied, "roles/iap.tunnelResourceAccessor"},
+		{fmt.Errorf("%w op after 1m", errOperationTimeout), ErrorTimeout, "wait_operation"},
+		{fmt.Errorf("cluster c %w in project p", ErrNotFound), ErrorNotFound, "list_clusters"},
+	} {
+		got := h.classifyError(tc.err, testProject)
+		if got == nil || got.Kind != tc.kind || !strings.Contains(got.Hint, tc.hint) {
+			t.Errorf("classifyError(%v) = %+v, want %s with a hint containing %q", tc.err, got, tc.kind, tc.hint)
+		}
+	}
+	if got := h.classifyError(errors.New("boom"), testProject); got != nil {
+		t.Errorf("classifyError(boom) = %+v, want nil", got)
+	}
+}
+
+func TestListClustersPages(t *testing.T) {
+	h, fake := newTestHandlers(t)
+
+	var names []string
+	args := map[string]any{"page_size": 3}
+	for pages := 1; ; pages++ {
+		out, isErr := callTool(t, h.listClusters, args)
+		if isErr {
+			t.Fatalf("list_clusters(%v) failed: %s", args, out)
+		}
+		var listing FleetListing
+		if err := json.Unmarshal([]byte(out), &listing); err != nil {
+			t.Fatal(err)
+		}
+		if len(listing.Clusters) > 3 {
+			t.Errorf("page %d has %d clusters, want at most 3", pages, len(listing.Clusters))
+		}
+		for _, c := range listing.Clusters {
+			names = append(names, c.Name)
+		}
+		if listing.NextPageToken == "" {
+			break
+		}
+		if pages == 10 {
+			t.Fatal("list_clusters never returned the last page")
+		}
+		args["page_token"] = listing.NextPageToken
+		// Later pages come from the listing of the first one.
+		fake.AddCluster(Cluster{Name: ClusterResourceName(testProject, "us-central1", fmt.Sprintf("added-%d", pages))})
+		h.clusterLists.clear()
+	}
+	if got, want := len(names), len(loadTestClusters(t)); got != want {
+		t.Errorf("pages of list_clusters hold %d clusters %v, want %d", got, names, want)
+	}
+
+	h.fleetPages.clear()
+	if out, isErr := callTool(t, h.listClusters, args); !isErr || !strings.Contains(out, "expired") {
+		t.Errorf("list_clusters with an expired page_token = %s, want an error", out)
+	}
+
+	if out, isErr := callTool(t, h.listClusters, map[string]any{"page_token": "not a token"}); !isErr {
+		t.Errorf("list_clusters with an invalid page_token = %s, want an error", out)
+	}
+}
+
+func TestTTLCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := newTTLCache[string, int](time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.set("a", 1)
+	if v, fetched, ok := c.get("a"); !ok || v != 1 || !fetched.Equal(now) {
+		t.Errorf("get(a) = %d, %v, %t, want 1 fetched now", v, fetched, ok)
+	}
+	now = now.Add(time.Minute)
+	if _, _, ok := c.get("a"); ok {
+		t.Error("get(a) returned an expired entry")
+	}
+
+	c.setFetched("old", 0, now.Add(-time.Minute))
+	c.set("new", 3)
+	c.prune()
+	if len(c.entries) != 1 {
+		t.Errorf("prune() left %d entries, want only the unexpired one", len(c.entries))
+	}
+
+	c.set("b", 2)
+	c.clear()
+	if _, _, ok := c.get("b"); ok {
+		t.Error("get(b) returned an entry after clear")
+	}
+}
+
+func TestClusterCache(t *testing.T) {
+	h, fake := newTestHandlers(t)
+	if out, isErr := callTool(t, h.listClusters, nil); isErr || !strings.Contains(out, `"cacheAge"`) {
+		t.Fatalf("list_clusters = %s, want a cacheAge", out)
+	}
+
+	fresh := ClusterResourceName(testProject, "us-central1", "fresh")
+	fake.AddCluster(Cluster{Name: fresh})
+	if out, _ := callTool(t, h.listClusters, nil); strings.Contains(out, `"fresh"`) {
+		t.Errorf("list_clusters did not use the cached listing: %s", out)
+	}
+	if out, _ := callTool(t, h.listClusters, map[string]any{"refresh": true}); !strings.Contains(out, `"fresh"`) {
+		t.Errorf("list_clusters with refresh does not show the new cluster: %s", out)
+	}
+
+	// A cluster missing from the cached listings is looked for again.
+	newer := ClusterResourceName(testProject, "europe-west4", "newer")
+	fake.AddCluster(Cluster{Name: newer})
+	out, isErr := callTool(t, h.getCluster, map[string]any{"clusterName": "newer"})
+	if isErr || !strings.Contains(out, newer) || !strings.Contains(out, `"cacheAge"`) {
+		t.Errorf("get_cluster(newer) = %s, want the cluster and its cacheAge", out)
+	}
+
+	// Invalidated clusters are fetched again.
+	if _, err := fake.DeleteCluster(context.Background(), newer); err != nil {
+		t.Fatal(err)
+	}
+	if out, isErr := callTool(t, h.getCluster, map[string]any{"clusterName": "newer"}); isErr {
+		t.Errorf("get_cluster(newer) did not use the cached cluster: %s", out)
+	}
+	h.forgetClusterState(newer)
+	if out, isErr := callTool(t, h.getCluster, map[string]any{"clusterName": "newer"}); !isErr {
+		t.Errorf("get_cluster(newer) after invalidation = %s, want an error", out)
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	// The clusters are served in pages of two.
+	clusters := loadTestClusters(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("Authorization header = %q", got)
+		}
+		switch r.URL.Path {
+		case "/v1alpha/projects/" + testProject + "/locations":
+			w.Write([]byte(`{"locations":[{"name":"projects/hpc-toolkit-dev/locations/us-central1","locationId":"us-central1"}]}`))
+		case "/v1alpha/projects/" + testProject + "/locations/us-central1/clusters":
+			page := struct {
+				Clusters      []Cluster `json:"clusters"`
+				NextPageToken string    `json:"nextPageToken,omitempty"`
+			}{Clusters: clusters[:2], NextPageToken: "page-2"}
+			if r.URL.Query().Get("pageToken") == "page-2" {
+				page.Clusters, page.NextPageToken = clusters[2:], ""
+			}
+			json.NewEncoder(w).Encode(page)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	api := NewHTTPClient(srv.URL+"/v1alpha/", testTokens)
+	ctx := context.Background()
+
+	locations, err := api.ListLocations(ctx, testProject)
+	if err != nil {
+		t.Fatalf("ListLocations() failed: %v", err)
+	}
+	if len(locations) != 1 || locations[0].LocationID != "us-central1" {
+		t.Errorf("ListLocations() = %+v", locations)
+	}
+
+	listed, err := api.ListClusters(ctx, testProject, "us-central1")
+	if err != nil {
+		t.Fatalf("ListClusters() failed: %v", err)
+	}
+	if len(listed) != 4 {
+		t.Errorf("ListClusters() returned %d clusters of both pages, want 4", len(listed))
+	}
+
+	if _, err := api.GetCluster(ctx, ClusterResourceName(testProject, "us-central1", "missing")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCluster() of a missing cluster error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestCreateCluster(t *testing.T) {
+	h, fake := newTestHandlers(t)
+	spec := loadTestClusters(t)[2]
+	args := map[string]any{
+		"location":   "us-central1",
+		"cluster_id": "newcluster",
+		"cluster":    spec,
+	}
+
+	out, isErr := callTool(t, h.createCluster, args)
+	if isErr {
+		t.Fatalf("create_cluster plan failed: %s", out)
+	}
+	if !strings.Contains(out, "Plan: create cluster newcluster") {
+		t.Errorf("create_cluster did not return a plan: %s", out)
+	}
+	token := regexp.MustCompile(`confirmation_token: (\w+)`).FindStringSubmatch(out)
+	if token == nil {
+		t.Fatalf("create_cluster plan has no confirmation token: %s", out)
+	}
+	name := ClusterResourceName(testProject, "us-central1", "newcluster")
+	if _, err := fake.GetCluster(context.Background(), name); err == nil {
+		t.Fatal("create_cluster created the cluster before it was confirmed")
+	}
+
+	args["confirmation_token"] = "0123456789abcdef"
+	if out, isErr := callTool(t, h.createCluster, args); !isErr {
+		t.Errorf("create_cluster accepted a wrong confirmation token: %s", out)
+	}
+
+	args["confirmation_token"] = token[1]
+	if out, isErr := callTool(t, h.createCluster, args); isErr {
+		t.Fatalf("create_cluster failed: %s", out)
+	}
+	if _, err := fake.GetCluster(context.Background(), name); err != nil {
+		t.Errorf("cluster was not created: %v", err)
+	}
+}
+
+func TestValidateCluster(t *testing.T) {
+	spec := loadTestClusters(t)[2]
+	if problems := validateCluster("us-central1", "newcluster", &spec); len(problems) != 0 {
+		t.Errorf("validateCluster() of a valid spec = %v", problems)
+	}
+	if problems := validateCluster("europe-west4", "NewCluster", &spec); len(problems) != 3 {
+		t.Errorf("validateCluster() with a bad ID and location = %v, want 3 problems", problems)
+	}
+
+	spec.Orchestrator.Slurm.DefaultPartition = "missing"
+	spec.Orchestrator.Slurm.NodeSets[0].ResourceRequestID = "missing"
+	if problems := validateCluster("us-central1", "newcluster", &spec); len(problems) != 2 {
+		t.Errorf("validateCluster() with dangling references = %v, want 2 problems", problems)
+	}
+}
+
+func TestDeleteCluster(t *testing.T) {
+	h, fake := newTestHandlers(t)
+
+	args := map[string]any{"clusterName": "quadrant", "confirm_cluster_name": "quadrant2"}
+	if out, isErr := callTool(t, h.deleteCluster, args); !isErr {
+		t.Errorf("delete_cluster accepted a mismatched confirmation: %s", out)
+	}
+
+	args["confirm_cluster_name"] = "quadrant"
+	for _, timeout := range []any{0, -5, 0.5} {
+		args["timeout_minutes"] = timeout
+		if out, isErr := callTool(t, h.deleteCluster, args); !isErr || !strings.Contains(out, "timeout_minutes") {
+			t.Errorf("delete_cluster with timeout_minutes %v = %s, want an error", timeout, out)
+		}
+	}
+	delete(args, "timeout_minutes")
+	if _, err := fake.GetCluster(context.Background(), ClusterResourceName(testProject, "us-central1", "quadrant")); err != nil {
+		t.Fatalf("delete_cluster with an invalid timeout deleted the cluster: %v", err)
+	}
+	if out, isErr := callTool(t, h.deleteCluster, args); isErr {
+		t.Fatalf("delete_cluster failed: %s", out)
+	}
+	if _, err := fake.GetCluster(context.Background(), ClusterResourceName(testProject, "us-central1", "quadrant")); err == nil {
+		t.Error("cluster was not deleted")
+	}
+	if out, _ := callTool(t, h.listClusters, nil); strings.Contains(out, `"quadrant"`) {
+		t.Errorf("list_clusters still shows the deleted cluster: %s", out)
+	}
+}
+
+func TestWaitForOperationTimeout(t *testing.T) {
+	h, fake := newTestHandlers(t)
+	h.pollInterval = time.Millisecond
+	name := LocationName(testProject, "us-central1") + "/operations/pending"
+	fake.AddOperation(Operation{Name: name})
+
+	op, err := h.waitForOperation(context.Background(), name, 20*time.Millisecond, nil)
+	if !errors.Is(err, errOperationTimeout) {
+		t.Errorf("waitForOperation() error = %v, want %v", err, errOperationTimeout)
+	}
+	if op == nil || op.Name != name {
+		t.Errorf("waitForOperation() = %+v, want the pending operation", op)
+	}
+}
+
+func TestWaitForOperationNotFound(t *testing.T) {
+	h, _ := newTestHandlers(t)
+	h.pollInterval = time.Millisecond
+	name := LocationName(testProject, "us-central1") + "/operations/mistyped"
+
+	start := time.Now()
+	_, err := h.waitForOperation(context.Background(), name, time.Minute, nil)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("waitForOperation() of a missing operation = %v, want %v", err, ErrNotFound)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("waitForOperation() of a missing operation took %s, want it to fail at once", elapsed)
+	}
+
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{&genericCore.APIError{StatusCode: http.StatusForbidden}, false},
+		{&genericCore.APIError{StatusCode: http.StatusUnauthorized}, false},
+		{&genericCore.APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{auth.ErrNoAccessToken, false},
+		{errors.New("connection reset"), true},
+	} {
+		if got := transientError(tc.err); got != tc.want {
+			t.Errorf("transientError(%v) = %t, want %t", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestOperationTools(t *testing.T) {
+	h, fake := newTestHandlers(t)
+	running := LocationName(testProject, "us-central1") + "/operations/running"
+	fake.AddOperation(Operation{
+		Name:     running,
+		Metadata: OperationMetadata{Verb: "update", Target: ClusterResourceName(testProject, "us-central1", "quadrant")},
+	})
+	failed := LocationName(testProject, "europe-west4") + "/operations/failed"
+	fake.AddOperation(Operation{Name: failed, Done: true, Error: &OperationError{Code: 7, Message: "permission denied"}})
+
+	out, _ := callTool(t, h.listOperations, map[string]any{"state": "running"})
+	if !strings.Contains(out, running) || strings.Contains(out, failed) {
+		t.Errorf("list_operations(state=running) = %s", out)
+	}
+
+	fake.FailListOperations(testProject, "europe-west4", fmt.Errorf("operations: %w", ErrNotFound))
+	out, isErr := callTool(t, h.listOperations, nil)
+	if isErr || !strings.Contains(out, running) || !strings.Contains(out, "  - europe-west4: operations: not found") || !strings.Contains(out, "Kind: NOT_FOUND") {
+		t.Errorf("list_operations with a failing region = %s, want europe-west4 reported", out)
+	}
+
+	out, isErr = callTool(t, h.getOperation, map[string]any{"operation": "failed", "location": "europe-west4"})
+	if isErr || !strings.Contains(out, "FAILED (code 7): permission denied") {
+		t.Errorf("get_operation = %s", out)
+	}
+
+	if out, isErr := callTool(t, h.getOperation, map[string]any{"operation": "failed"}); !isErr {
+		t.Errorf("get_operation without a location succeeded: %s", out)
+	}
+
+	out, isErr = callTool(t, h.waitOperation, map[string]any{"operation": "failed", "location": "europe-west4", "timeout_minutes": 0.5})
+	if !isErr || !strings.Contains(out, "timeout_minutes must be a whole number") {
+		t.Errorf("wait_operation with timeout_minutes 0.5 = %s, want an error", out)
+	}
+	out, isErr = callTool(t, h.waitOperation, map[string]any{"operation": "failed", "location": "europe-west4", "timeout_minutes": 1})
+	if isErr || !strings.Contains(out, "FAILED (code 7)") {
+		t.Errorf("wait_operation = %s", out)
+	}
+}
+
+func TestUpdateNodeSetSize(t *testing.T) {
+	h, fake := newTestHandlers(t)
+	h.pollInterval = time.Millisecond
+
+	args := map[string]any{"clusterName": "cluster0vk", "nodeset_id": "nodeset1", "static_node_count": 4, "timeout_minutes": -1}
+	if out, isErr := callTool(t, h.updateNodeSetSize, args); !isErr || !strings.Contains(out, "timeout_minutes") {
+		t.Errorf("update_nodeset_size with timeout_minutes -1 = %s, want an error", out)
+	}
+	delete(args, "timeout_minutes")
+	out, isErr := callTool(t, h.updateNodeSetSize, args)
+	if isErr {
+		t.Fatalf("update_nodeset_size failed: %s", out)
+	}
+	for _, want := range []string{"before: 2 x n2-standard-2", "after:  4 x n2-standard-2"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("update_nodeset_size output %q does not contain %q", out, want)
+		}
+	}
+	got, err := fake.GetCluster(context.Background(), ClusterResourceName(testProject, "us-central1", "cluster0vk"))
+	if err != nil {
+		t.Fatalf("GetCluster() failed: %v", err)
+	}
+	if n := got.Orchestrator.Slurm.NodeSets[0].StaticNodeCount; n != "4" {
+		t.Errorf("staticNodeCount = %s, want 4", n)
+	}
+
+	args["nodeset_id"] = "missing"
+	if out, isErr := callTool(t, h.updateNodeSetSize, args); !isErr {
+		t.Errorf("update_nodeset_size of a missing node set succeeded: %s", out)
+	}
+	if _, err := h.waitForReconcile(context.Background(), ClusterResourceName(testProject, "us-central1", "deleted"), time.Minute); !errors.Is(err, ErrNotFound) {
+		t.Errorf("waitForReconcile() of a missing cluster = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestSlurmBackends(t *testing.T) {
+	h, fake := newTestHandlers(t)
+	name := ClusterResourceName(testProject, "us-central1", "cluster0vk")
+	fake.SetSlurmResponse(name, "/slurm/v0.0.42/nodes/",
+		`{"nodes":[{"name":"cluster0vk-nodeset1-0","state":["IDLE"],"partitions":["debug"],"cpus":2,"real_memory":{"set":true,"number":7000}},`+
+			`{"name":"cluster0vk-nodeset1-1","state":["IDLE","DRAIN"],"partitions":["debug"],"cpus":2}]}`)
+	cluster, err := fake.GetCluster(context.Background(), name)
+	if err != nil {
+		t.Fatalf("GetCluster() failed: %v", err)
+	}
+	cluster.Orchestrator.Slurm.LoginNodes.Instances = append(cluster.Orchestrator.Slurm.LoginNodes.Instances,
+		LoginInstance{Instance: "projects/hpc-toolkit-dev/zones/us-central1-a/instances/cluster0vk-login-002"})
+	fake.AddCluster(*cluster)
+
+	// The first login node is down
+	var sshCmds, sshHosts []string
+	fakeLoginNodes(h, func(node remote.Node, cmd string, stdin []byte) (string, error) {
+		sshHosts = append(sshHosts, node.Project+"/"+node.Zone+"/"+node.Instance)
+		if node.Instance == "cluster0vk-login-001" {
+			return "", &remote.ConnectError{Node: node, Err: errors.New("connection timed out")}
+		}
+		sshCmds = append(sshCmds, cmd)
+		return "7|alice|debug|RUNNING|None|1:02|1|cluster0vk-nodeset1-0|gpu:1|train", nil
+	})
+
+	out, isErr := callTool(t, h.showClusterState, map[string]any{"clusterName": "cluster0vk"})
+	if isErr {
+		t.Fatalf("show_cluster_state failed: %s", out)
+	}
+	for _, want := range []string{`"backend": "rest"`, "idle      1  cluster0vk-nodeset1-0", "drain     1  cluster0vk-nodeset1-1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("show_cluster_state output %q does not contain %q", out, want)
+		}
+	}
+	if len(sshCmds) != 0 {
+		t.Errorf("show_cluster_state ran %q over ssh although the REST backend works", sshCmds)
+	}
+
+	// The REST API has no jobs response, so auto falls back to ssh
+	out, isErr = callTool(t, h.showJobState, map[string]any{"clusterName": "cluster0vk"})
+	if isErr {
+		t.Fatalf("show_job_state failed: %s", out)
+	}
+	for _, want := range []string{`"backend": "ssh"`, `"id": "7"`, "7 train (alice) RUNNING on debug"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("show_job_state output %q does not contain %q", out, want)
+		}
+	}
+
+	out, isErr = callTool(t, h.showJobState, map[string]any{"clusterName": "cluster0vk", "slurm_backend": "rest"})
+	if !isErr {
+		t.Errorf("show_job_state with the rest backend succeeded: %s", out)
+	}
+
+	// The login node that answered is tried first from now on
+	callTool(t, h.showJobState, map[string]any{"clusterName": "cluster0vk", "slurm_backend": "ssh"})
+	want := []string{
+		"hpc-toolkit-dev/us-central1-c/cluster0vk-login-001",
+		"hpc-toolkit-dev/us-central1-a/cluster0vk-login-002",
+		"hpc-toolkit-dev/us-central1-a/cluster0vk-login-002",
+	}
+	if strings.Join(sshHosts, " ") != strings.Join(want, " ") {
+		t.Errorf("ssh went to %q, want %q", sshHosts, want)
+	}
+
+	// A command that ran and failed is not retried on another login node
+	sshHosts = nil
+	fakeLoginNodes(h, func(node remote.Node, cmd string, stdin []byte) (string, error) {
+		sshHosts = append(sshHosts, node.Instance)
+		return "", &remote.ExitError{Status: 1, Stderr: "slurm_load_jobs error"}
+	})
+	out, isErr = callTool(t, h.showJobState, map[string]any{"clusterName": "cluster0vk", "slurm_backend": "ssh"})
+	if !isErr || !strings.Contains(out, "slurm_load_jobs error") {
+		t.Errorf("show_job_state with a failing squeue = %q, want the error", out)
+	}
+	if len(sshHosts) != 1 {
+		t.Errorf("failing squeue ran on %q, want a single login node", sshHosts)
+	}
+
+	// What answered is remembered for the cluster, not for every cluster of
+	// the same name.
+	if node, _, ok := h.loginNodeFor.get(name); !ok || node != "cluster0vk-login-002" {
+		t.Errorf("login node remembered for %s = %q, want cluster0vk-login-002", name, node)
+	}
+	if _, _, ok := h.loginNodeFor.get("cluster0vk"); ok {
+		t.Error("login node remembered by cluster name only")
+	}
+}
+
+func TestParseSinfo(t *testing.T) {
+	out := "debug*|up|idle|3|a3-[001-002],login-001\n" +
+		"debug*|up|drained*|1|a3-003\n" +
+		"gpu|up|allocated|4|rack[1-2]-n[08-09]\n" +
+		"gpu|down|mixed|0|\n"
+	records, err := parseSinfo(out)
+	if err != nil {
+		t.Fatalf("parseSinfo() failed: %v", err)
+	}
+	state := newClusterState(records)
+	if len(state.Partitions) != 2 {
+		t.Fatalf("got %d partitions, want 2: %+v", len(state.Partitions), state)
+	}
+	debug, gpu := state.Partitions[0], state.Partitions[1]
+	if !debug.Default || debug.Nodes != 4 || gpu.Default || gpu.Nodes != 4 {
+		t.Errorf("partitions = %+v, want default debug and gpu with 4 nodes each", state.Partitions)
+	}
+	if got := debug.States[0]; got.State != NodeStateDrain || got.SlurmState != "drained*" {
+		t.Errorf("first debug state = %+v, want drain", got)
+	}
+	want := []string{"rack1-n08", "rack1-n09", "rack2-n08", "rack2-n09"}
+	if got := gpu.States[0].Nodes; gpu.States[0].State != NodeStateAlloc || strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("gpu alloc state = %+v, want nodes %v", gpu.States[0], want)
+	}
+	if got := strings.Join(debug.States[1].Nodes, ","); got != "a3-001,a3-002,login-001" {
+		t.Errorf("debug idle nodes = %s", got)
+	}
+
+	for _, bad := range []string{"debug|up|idle|1", "debug|up|idle|x|a", "debug|up|idle|1|a[1-"} {
+		if _, err := parseSinfo(bad); err == nil {
+			t.Errorf("parseSinfo(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestParseSqueue(t *testing.T) {
+	out := "7|alice|gpu|RUNNING|None|1-02:03:04|2|a3-[001-002]|gpu:8|train|v2\n" +
+		"8|alice|gpu|PENDING|Resources|0:00|4||gpu:8|train\n" +
+		"9|bob|debug|PENDING|Priority|0:00|1|(null)|N/A|eval\n"
+	jobs, err := parseSqueue(out)
+	if err != nil {
+		t.Fatalf("parseSqueue() failed: %v", err)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("got %d jobs, want 3", len(jobs))
+	}
+	want := JobRecord{ID: "7", Name: "train|v2", User: "alice", Partition: "gpu", State: "RUNNING",
+		Elapsed: "1-02:03:04", NodeCount: 2, Nodes: "a3-[001-002]", GRES: "gpu:8"}
+	if jobs[0] != want {
+		t.Errorf("first job = %+v, want %+v", jobs[0], want)
+	}
+
+	state := newJobState(jobs, JobFilter{State: "PD"}, 1)
+	if state.Total != 2 || len(state.Jobs) != 1 || state.ByUser["bob"]["PENDING"] != 1 {
+		t.Errorf("pending jobs = %+v, want 2 jobs of which 1 listed", state)
+	}
+	summary := state.Summary()
+	for _, want := range []string{"2 job(s): PENDING 2", "Pending because of: Priority 1, Resources 1", "Listing the first 1 job(s)"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary %q does not contain %q", summary, want)
+		}
+	}
+	if state := newJobState(jobs, JobFilter{User: "alice", Name: "train", Partition: "gpu"}, 0); state.Total != 2 {
+		t.Errorf("alice's train jobs = %d, want 2", state.Total)
+	}
+
+	if _, err := parseSqueue("7|alice|gpu"); err == nil {
+		t.Error("parseSqueue() of a short line succeeded")
+	}
+	if got := formatSlurmDuration(62 * time.Second); got != "1:02" {
+		t.Errorf("formatSlurmDuration(62s) = %s, want 1:02", got)
+	}
+}
+
+func TestSubmitJob(t *testing.T) {
+	h, fake := newTestHandlers(t)
+	var sshCmd, sshStdin string
+	fakeLoginNodes(h, func(node remote.Node, cmd string, stdin []byte) (string, error) {
+		sshCmd, sshStdin = cmd, string(stdin)
+		return "42;cluster0vk", nil
+	})
+	script := "#!/bin/bash\necho 'hello'\n"
+	args := map[string]any{"clusterName": "cluster0vk", "script": script,
+		"nodes": 2, "time_limit": "1:00:00", "job_name": "hello", "slurm_backend": "ssh"}
+	out, isErr := callTool(t, h.submitJob, args)
+	if isErr {
+		t.Fatalf("submit_job failed: %s", out)
+	}
+	if !strings.Contains(out, "Submitted job 42 to partition part1") {
+		t.Errorf("submit_job output %q does not report job 42", out)
+	}
+	if want := "/usr/local/bin/sbatch --parsable --partition=part1 --nodes=2 --time=1:00:00 --job-name=hello"; sshCmd != want || sshStdin != script {
+		t.Errorf("ran %q with stdin %q, want %q with the script", sshCmd, sshStdin, want)
+	}
+
+	fake.SetSlurmResponse(ClusterResourceName(testProject, "us-central1", "cluster0vk"), "/slurm/v0.0.42/job/submit", `{"job_id":43}`)
+	delete(args, "slurm_backend")
+	if out, isErr := callTool(t, h.submitJob, args); isErr || !strings.Contains(out, "Submitted job 43") {
+		t.Errorf("submit_job over rest = %q, want job 43", out)
+	}
+
+	// A request the CallSlurm API rejects never reached Slurm, so auto falls
+	// back to ssh, unlike after a failure that may have submitted the job.
+	name := ClusterResourceName(testProject, "us-central1", "cluster0vk")
+	fake.FailCallSlurm(name, &genericCore.APIError{StatusCode: http.StatusForbidden, Status: "PERMISSION_DENIED"})
+	if out, isErr := callTool(t, h.submitJob, args); isErr || !strings.Contains(out, "Submitted job 42") {
+		t.Errorf("submit_job after a 403 from CallSlurm = %q, want job 42 over ssh", out)
+	}
+	h.slurmBackendFor.set(name, SlurmBackendREST)
+	fake.FailCallSlurm(name, &genericCore.APIError{StatusCode: http.StatusInternalServerError})
+	sshCmd = ""
+	if out, isErr := callTool(t, h.submitJob, args); !isErr || sshCmd != "" {
+		t.Errorf("submit_job after a 500 from CallSlurm = %q and ran %q over ssh, want an error and no retry", out, sshCmd)
+	}
+
+	for name, bad := range map[string]map[string]any{
+		"too many nodes": {"nodes": 3},
+		"gpus":           {"gpus_per_node": 1},
+		"partition":      {"partition": "gpu"},
+		"time limit":     {"time_limit": "1h"},
+		"no shebang":     {"script": "echo hi"},
+		"both scripts":   {"script_path": "/home/shared/job.sh"},
+	} {
+		bad["clusterName"] = "cluster0vk"
+		if _, ok := bad["script"]; !ok {
+			bad["script"] = script
+		}
+		if out, isErr := callTool(t, h.submitJob, bad); !isErr {
+			t.Errorf("submit_job with %s succeeded: %s", name, out)
+		}
+	}
+
+	// Slurm queues jobs on partitions scaled down to no static nodes.
+	cluster := Cluster{Name: name, Orchestrator: Orchestrator{Slurm: Slurm{
+		NodeSets:   []NodeSet{{ID: "ns1", StaticNodeCount: "0"}},
+		Partitions: []Partition{{ID: "part1", NodeSetIDs: []string{"ns1"}}},
+	}}}
+	sub := JobSubmission{Script: script, Partition: "part1", Nodes: 4}
+	if problems := validateSubmission(&cluster, &sub); len(problems) > 0 {
+		t.Errorf("validateSubmission on a partition without static nodes = %q, want no problems", problems)
+	}
+
+	if got, err := parseTimeLimit("2-01:30"); err != nil || got != 49*60+30 {
+		t.Errorf("parseTimeLimit(2-01:30) = %d, %v, want %d", got, err, 49*60+30)
+	}
+}
+
+func TestJobControl(t *testing.T) {
+	h, _ := newTestHandlers(t)
+	queue := map[string]string{"7": "7|alice|part1|RUNNING|None|1:02|1|n1||train\n", "8": "8|bob|part1|PENDING|Priority|0:00|1|||eval\n"}
+	var ran []string
+	fakeLoginNodes(h, func(node remote.Node, cmd string, stdin []byte) (string, error) {
+		switch {
+		case cmd == "/usr/bin/id -un":
+			return "alice", nil
+		case strings.HasPrefix(cmd, "/usr/local/bin/squeue "):
+			return queue["7"] + queue["8"], nil
+		case strings.HasPrefix(cmd, "/usr/local/bin/scancel "):
+			ran = append(ran, cmd)
+			delete(queue, strings.TrimPrefix(cmd, "/usr/local/bin/scancel "))
+			return "", nil
+		}
+		ran = append(ran, cmd)
+		return "", nil
+	})
+	args := map[string]any{"clusterName": "cluster0vk", "slurm_backend": "ssh", "job_id": "8"}
+
+	if out, isErr := callTool(t, h.controlJob(JobActionCancel), args); !isErr || !strings.Contains(out, "belongs to bob") {
+		t.Errorf("cancel_job of another user's job = %q, want an error", out)
+	}
+	if len(ran) != 0 {
+		t.Errorf("cancel_job of another user's job ran %q", ran)
+	}
+
+	args["allow_other_users"] = true
+	if out, isErr := callTool(t, h.controlJob(JobActionHold), args); isErr || !strings.Contains(out, "Held job 8") {
+		t.Errorf("hold_job = %q", out)
+	}
+
+	delete(args, "allow_other_users")
+	args["job_id"] = "7"
+	out, isErr := callTool(t, h.controlJob(JobActionCancel), args)
+	if isErr || !strings.Contains(out, "no longer in the queue") {
+		t.Errorf("cancel_job = %q, want the job to have left the queue", out)
+	}
+	if want := []string{"/usr/local/bin/scontrol hold 8", "/usr/local/bin/scancel 7"}; strings.Join(ran, ";") != strings.Join(want, ";") {
+		t.Errorf("ran %q, want %q", ran, want)
+	}
+
+	args["job_id"] = "7; reboot"
+	if out, isErr := callTool(t, h.controlJob(JobActionCancel), args); !isErr {
+		t.Errorf("cancel_job with an invalid job ID succeeded: %s", out)
+	}
+}
+
+func TestJobHistory(t *testing.T) {
+	h, _ := newTestHandlers(t)
+	var sacctCmd string
+	fakeLoginNodes(h, func(node remote.Node, cmd string, stdin []byte) (string, error) {
+		sacctCmd = cmd
+		return "11|alice|gpu|COMPLETED|02:00:00|7200|0:0||cpu=8,gres/gpu=8,node=1|1|2025-06-30T08:00:00|2025-06-30T10:00:00|train\n" +
+			"11.batch||||02:00:00|7200|0:0|2048M|cpu=8,gres/gpu=8,node=1|1|2025-06-30T08:00:00|2025-06-30T10:00:00|batch\n" +
+			"12|bob|gpu|FAILED|00:30:00|1800|1:0||cpu=4,gres/gpu:a100=2,node=1|1|2025-06-30T09:00:00|2025-06-30T09:30:00|eval|v2\n" +
+			"12.0||||00:30:00|1800|1:0|512K||1|2025-06-30T09:00:00|2025-06-30T09:30:00|python\n" +
+			"13|bob|debug|CANCELLED by 1000|00:00:10|10|0:15||cpu=1,node=1|1|2025-06-30T09:00:00|2025-06-30T09:00:10|x\n", nil
+	})
+	args := map[string]any{"clusterName": "cluster0vk", "since": "2025-06-30", "user": "bob"}
+	out, isErr := callTool(t, h.jobHistory, args)
+	if isErr {
+		t.Fatalf("job_history failed: %s", out)
+	}
+	for _, want := range []string{
+		"3 job(s) between 2025-06-30 and now: CANCELLED 1, COMPLETED 1, FAILED 1",
+		"12 eval|v2 (bob) FAILED, exit code 1:0 after 00:30:00, MaxRSS 512K",
+		"GPU-hours: 17.0 (alice 16.0, bob 1.0)",
+		`"maxRssBytes": 2147483648`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("job_history output %q does not contain %q", out, want)
+		}
+	}
+	if !strings.Contains(sacctCmd, "--starttime=2025-06-30 --endtime=now") || !strings.Contains(sacctCmd, "--user=bob") {
+		t.Errorf("sacct command %q does not select the window and user", sacctCmd)
+	}
+
+	args["slurm_backend"] = SlurmBackendREST
+	out, isErr = callTool(t, h.jobHistory, args)
+	if !isErr || !strings.Contains(out, "Kind: SSH_ONLY") || !strings.Contains(out, "slurm_backend=ssh") {
+		t.Errorf("job_history over rest returned %q, want an SSH_ONLY error with a hint", out)
+	}
+	delete(args, "slurm_backend")
+
+	args["since"] = "yesterday; rm -rf ~"
+	if out, isErr := callTool(t, h.jobHistory, args); !isErr {
+		t.Errorf("job_history with an invalid time succeeded: %s", out)
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// clusterIDPattern is the RFC 1035 label format Cluster Director requires for
+// cluster IDs.
+var clusterIDPattern = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+func (h *handlers) createCluster(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	projectID := request.GetString("project_id", h.c.GetDefaultProjectID())
+	if projectID == "" {
+		return mcp.NewToolResultError("project_id argument not set"), nil
+	}
+	location, err := request.RequireString("location")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	clusterID, err := request.RequireString("cluster_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	cluster, err := clusterFromArgument(request.GetArguments()["cluster"])
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	confirmationToken := request.GetString("confirmation_token", "")
+
+	if problems := validateCluster(location, clusterID, cluster); len(problems) > 0 {
+		return mcp.NewToolResultError("The cluster spec is invalid:\n  - " + strings.Join(problems, "\n  - ")), nil
+	}
+
+	token, err := h.planToken(projectID, location, clusterID, cluster)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if confirmationToken == "" {
+		plan := describeClusterPlan(projectID, location, clusterID, cluster)
+		return mcp.NewToolResultText(plan + "\n" +
+			"Nothing has been created yet. Show this plan to the user. Only if the user explicitly approves it, " +
+			"call create_cluster again with exactly the same arguments and confirmation_token: " + token), nil
+	}
+	if !hmac.Equal([]byte(confirmationToken), []byte(token)) {
+		return mcp.NewToolResultError("confirmation_token does not match this cluster spec. " +
+			"The arguments changed since the plan was shown, call create_cluster without a confirmation_token to get a new plan."), nil
+	}
+
+	op, err := h.api.CreateCluster(ctx, projectID, location, clusterID, cluster)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not create cluster", "project", projectID, "location", location, "cluster", clusterID, "error", err)
+		return h.toolError(fmt.Errorf("could not create cluster %s: %w", clusterID, err), projectID), nil
+	}
+	h.forgetClusterState(ClusterResourceName(projectID, location, clusterID))
+	return mcp.NewToolResultText(fmt.Sprintf("Creation of cluster %s started. Use wait_operation to follow it.\n%s",
+		ClusterResourceName(projectID, location, clusterID), describeOperation(op))), nil
+}
+
+// clusterFromArgument decodes the "cluster" tool argument, which clients may
+// send either as a JSON object or as a string holding one.
+func clusterFromArgument(arg interface{}) (*Cluster, error) {
+	var data []byte
+	switch v := arg.(type) {
+	case nil:
+		return nil, fmt.Errorf("required argument \"cluster\" not found")
+	case string:
+		data = []byte(v)
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("could not read the cluster argument: %w", err)
+		}
+	}
+
+	var cluster Cluster
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cluster); err != nil {
+		return nil, fmt.Errorf("the cluster argument is not a valid cluster spec: %w", err)
+	}
+	// These fields are set by the service
+	cluster.Name = ""
+	cluster.CreateTime = ""
+	cluster.UpdateTime = ""
+	cluster.Reconciling = false
+	cluster.Orchestrator.Slurm.LoginNodes.Instances = nil
+	return &cluster, nil
+}
+
+// planToken returns the confirmation token for creating cluster. It is keyed
+// with a per-process secret so that it can only be obtained from a plan.
+func (h *handlers) planToken(projectID string, location string, clusterID string, cluster *Cluster) (string, error) {
+	spec, err := json.Marshal(cluster)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal the cluster spec: %w", err)
+	}
+	mac := hmac.New(sha256.New, h.planKey)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n", projectID, location, clusterID)
+	mac.Write(spec)
+	return hex.EncodeToString(mac.Sum(nil))[:16], nil
+}
+
+// parseCount parses the int64 counts the API encodes as JSON strings.
+func parseCount(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// validateCluster checks that cluster is internally consistent and can be
+// created in location. It returns one message per problem found.
+func validateCluster(location string, clusterID string, cluster *Cluster) []string {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	inLocation := func(zone string) bool {
+		return strings.HasPrefix(zone, location+"-")
+	}
+
+	if !clusterIDPattern.MatchString(clusterID) {
+		addf("cluster_id %q must start with a lowercase letter, contain only lowercase letters, digits and hyphens, and be at most 63 characters", clusterID)
+	}
+
+	if len(cluster.Networks) == 0 {
+		addf("at least one network is required")
+	}
+	for i, n := range cluster.Networks {
+		if n.Network == "" && n.InitializeParams.Network == "" {
+			addf("networks[%d] must set either network or initializeParams.network", i)
+		}
+	}
+
+	storageIDs := make(map[string]bool)
+	for i, st := range cluster.Storages {
+		switch {
+		case st.ID == "":
+			addf("storages[%d] has no id", i)
+		case storageIDs[st.ID]:
+			addf("storage id %q is used more than once", st.ID)
+		}
+		storageIDs[st.ID] = true
+		if st.Storage == "" && st.InitializeParams.Filestore.Filestore == "" {
+			addf("storage %q must set either storage or initializeParams.filestore", st.ID)
+		}
+		for _, share := range st.InitializeParams.Filestore.FileShares {
+			if gb, err := parseCount(share.CapacityGb); err != nil || gb <= 0 {
+				addf("storage %q file share %q has invalid capacityGb %q", st.ID, share.FileShare, share.CapacityGb)
+			}
+		}
+	}
+	checkStorageConfigs := func(owner string, configs []StorageConfig) {
+		for _, sc := range configs {
+			if !storageIDs[sc.ID] {
+				addf("%s mounts unknown storage %q", owner, sc.ID)
+			}
+			if !strings.HasPrefix(sc.LocalMount, "/") {
+				addf("%s mounts storage %q at %q, which is not an absolute path", owner, sc.ID, sc.LocalMount)
+			}
+		}
+	}
+
+	rrIDs := make(map[string]bool)
+	if len(cluster.Compute.ResourceRequests) == 0 {
+		addf("at least one compute resourceRequest is required")
+	}
+	for i, rr := range cluster.Compute.ResourceRequests {
+		switch {
+		case rr.ID == "":
+			addf("compute.resourceRequests[%d] has no id", i)
+		case rrIDs[rr.ID]:
+			addf("resourceRequest id %q is used more than once", rr.ID)
+		}
+		rrIDs[rr.ID] = true
+		if rr.MachineType == "" {
+			addf("resourceRequest %q has no machineType", rr.ID)
+		}
+		if !inLocation(rr.Zone) {
+			addf("resourceRequest %q zone %q is not in location %s", rr.ID, rr.Zone, location)
+		}
+	}
+
+	slurm := cluster.Orchestrator.Slurm
+	nodeSetIDs := make(map[string]bool)
+	if len(slurm.NodeSets) == 0 {
+		addf("at least one Slurm nodeSet is required")
+	}
+	for i, ns := range slurm.NodeSets {
+		switch {
+		case ns.ID == "":
+			addf("orchestrator.slurm.nodeSets[%d] has no id", i)
+		case nodeSetIDs[ns.ID]:
+			addf("nodeSet id %q is used more than once", ns.ID)
+		}
+		nodeSetIDs[ns.ID] = true
+		if !rrIDs[ns.ResourceRequestID] {
+			addf("nodeSet %q refers to unknown resourceRequest %q", ns.ID, ns.ResourceRequestID)
+		}
+		if n, err := parseCount(ns.StaticNodeCount); err != nil || n < 0 {
+			addf("nodeSet %q has invalid staticNodeCount %q", ns.ID, ns.StaticNodeCount)
+		}
+		checkStorageConfigs("nodeSet "+strconv.Quote(ns.ID), ns.StorageConfigs)
+	}
+
+	partitionIDs := make(map[string]bool)
+	if len(slurm.Partitions) == 0 {
+		addf("at least one Slurm partition is required")
+	}
+	for i, p := range slurm.Partitions {
+		switch {
+		case p.ID == "":
+			addf("orchestrator.slurm.partitions[%d] has no id", i)
+		case partitionIDs[p.ID]:
+			addf("partition id %q is used more than once", p.ID)
+		}
+		partitionIDs[p.ID] = true
+		if len(p.NodeSetIDs) == 0 {
+			addf("partition %q has no nodeSetIds", p.ID)
+		}
+		for _, id := range p.NodeSetIDs {
+			if !nodeSetIDs[id] {
+				addf("partition %q refers to unknown nodeSet %q", p.ID, id)
+			}
+		}
+	}
+	if slurm.DefaultPartition != "" && !partitionIDs[slurm.DefaultPartition] {
+		addf("defaultPartition %q is not one of the partitions", slurm.DefaultPartition)
+	}
+
+	login := slurm.LoginNodes
+	if login.MachineType == "" {
+		addf("loginNodes has no machineType")
+	}
+	if !inLocation(login.Zone) {
+		addf("loginNodes zone %q is not in location %s", login.Zone, location)
+	}
+	if n, err := parseCount(login.Count); err != nil || n < 1 {
+		addf("loginNodes count %q must be at least 1", login.Count)
+	}
+	checkStorageConfigs("loginNodes", login.StorageConfigs)
+
+	return problems
+}
+
+// describeAccelerators renders the guestAccelerators of a resource request,
+// e.g. "8 x nvidia-h100-80gb".
+func describeAccelerators(accelerators []map[string]interface{}) string {
+	var parts []string
+	for _, acc := range accelerators {
+		if len(acc) == 0 {
+			continue
+		}
+		count, accType := parseAccelerator(acc)
+		parts = append(parts, fmt.Sprintf("%d x %s", count, accType))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeClusterPlan renders a human-readable summary of what creating
+// cluster will provision.
+func describeClusterPlan(projectID string, location string, clusterID string, cluster *Cluster) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Plan: create cluster %s in project %s, location %s\n", clusterID, projectID, location)
+
+	b.WriteString("\nNetworks:\n")
+	for _, n := range cluster.Networks {
+		if n.InitializeParams.Network != "" {
+			fmt.Fprintf(&b, "  - create %s\n", n.InitializeParams.Network)
+		} else {
+			fmt.Fprintf(&b, "  - use existing %s\n", n.Network)
+		}
+	}
+
+	b.WriteString("\nStorage:\n")
+	if len(cluster.Storages) == 0 {
+		b.WriteString("  - none\n")
+	}
+	for _, st := range cluster.Storages {
+		fs := st.InitializeParams.Filestore
+		if fs.Filestore == "" {
+			fmt.Fprintf(&b, "  - %s: use existing %s\n", st.ID, st.Storage)
+			continue
+		}
+		var shares []string
+		for _, share := range fs.FileShares {
+			shares = append(shares, fmt.Sprintf("%s (%s GB)", share.FileShare, share.CapacityGb))
+		}
+		fmt.Fprintf(&b, "  - %s: create Filestore %s, tier %s, shares %s\n",
+			st.ID, fs.Filestore, fs.Tier, strings.Join(shares, ", "))
+	}
+
+	b.WriteString("\nCompute resource requests:\n")
+	machines := make(map[string]ResourceRequest)
+	for _, rr := range cluster.Compute.ResourceRequests {
+		machines[rr.ID] = rr
+		fmt.Fprintf(&b, "  - %s: %s in %s, accelerators %s, provisioning %s\n",
+			rr.ID, rr.MachineType, rr.Zone, describeAccelerators(rr.GuestAccelerators), rr.ProvisioningModel)
+	}
+
+	slurm := cluster.Orchestrator.Slurm
+	b.WriteString("\nSlurm node sets:\n")
+	var totalNodes int64
+	for _, ns := range slurm.NodeSets {
+		n, _ := parseCount(ns.StaticNodeCount)
+		totalNodes += n
+		var mounts []string
+		for _, sc := range ns.StorageConfigs {
+			mounts = append(mounts, sc.LocalMount)
+		}
+		sort.Strings(mounts)
+		fmt.Fprintf(&b, "  - %s: %d static node(s) of %s (%s), mounts %s\n",
+			ns.ID, n, ns.ResourceRequestID, machines[ns.ResourceRequestID].MachineType, strings.Join(mounts, ", "))
+	}
+
+	b.WriteString("\nSlurm partitions:\n")
+	for _, p := range slurm.Partitions {
+		suffix := ""
+		if p.ID == slurm.DefaultPartition {
+			suffix = " (default)"
+		}
+		fmt.Fprintf(&b, "  - %s%s: node sets %s\n", p.ID, suffix, strings.Join(p.NodeSetIDs, ", "))
+	}
+
+	login := slurm.LoginNodes
+	fmt.Fprintf(&b, "\nLogin nodes: %s x %s in %s, public IPs %t, OS Login %t\n",
+		login.Count, login.MachineType, login.Zone, login.EnablePublicIps, login.EnableOsLogin)
+
+	fmt.Fprintf(&b, "\nTotal static compute nodes: %d\n", totalNodes)
+	return b.String()
+}
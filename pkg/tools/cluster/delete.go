@@ -0,0 +1,72 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func (h *handlers) deleteCluster(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	projectID := request.GetString("project_id", h.c.GetDefaultProjectID())
+	if projectID == "" {
+		return mcp.NewToolResultError("project_id argument not set"), nil
+	}
+	clusterName, err := request.RequireString("clusterName")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	confirmName, err := request.RequireString("confirm_cluster_name")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	timeout, err := operationTimeout(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if confirmName != clusterName {
+		return mcp.NewToolResultError(fmt.Sprintf("confirm_cluster_name %q does not match clusterName %q. "+
+			"Ask the user to re-type the exact name of the cluster to delete.", confirmName, clusterName)), nil
+	}
+
+	cluster, _, err := h.findCluster(ctx, projectID, clusterName, true)
+	if err != nil {
+		return h.toolError(err, projectID), nil
+	}
+
+	op, err := h.api.DeleteCluster(ctx, cluster.Name)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not delete cluster", "cluster", cluster.Name, "error", err)
+		return h.toolError(fmt.Errorf("could not delete cluster %s: %w", cluster.Name, err), projectID), nil
+	}
+	h.forgetClusterState(cluster.Name)
+
+	started := op
+	if op, err = h.waitForOperation(ctx, started.Name, timeout, operationProgress(ctx, request)); err != nil {
+		if op == nil {
+			op = started
+		}
+		return mcp.NewToolResultError(fmt.Sprintf("Deletion of cluster %s was started but has not finished: %s\n%s",
+			cluster.Name, h.explainError(err, projectID), describeOperation(op))), nil
+	}
+	if op.Error != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Deletion of cluster %s failed.\n%s", cluster.Name, describeOperation(op))), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Cluster %s was deleted.\n%s", cluster.Name, describeOperation(op))), nil
+}
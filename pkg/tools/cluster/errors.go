@@ -0,0 +1,194 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/auth"
+	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
+	"github.com/nadig-google/cluster-director-mcp/pkg/remote"
+)
+
+// ErrorKind is a class of failures the tools explain how to fix.
+type ErrorKind string
+
+const (
+	ErrorNotFound         ErrorKind = "NOT_FOUND"
+	ErrorPermissionDenied ErrorKind = "PERMISSION_DENIED"
+	ErrorAPIDisabled      ErrorKind = "API_DISABLED"
+	ErrorAuthExpired      ErrorKind = "AUTH_EXPIRED"
+	ErrorIAPDenied        ErrorKind = "IAP_DENIED"
+	ErrorTimeout          ErrorKind = "TIMEOUT"
+	ErrorSSHOnly          ErrorKind = "SSH_ONLY"
+)
+
+// ToolError is a failure of a tool classified by kind, with a hint on how
+// the user can fix it.
+type ToolError struct {
+	Kind ErrorKind
+	Hint string
+	Err  error
+}
+
+func (e *ToolError) Error() string { return e.Err.Error() }
+
+func (e *ToolError) Unwrap() error { return e.Err }
+
+// errorInfo is the google.rpc.ErrorInfo detail of a Google API error.
+type errorInfo struct {
+	Type     string            `json:"@type"`
+	Reason   string            `json:"reason"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// errorInfoOf returns the ErrorInfo among the details of e, if any.
+func errorInfoOf(e *genericCore.APIError) errorInfo {
+	for _, raw := range e.Details {
+		var info errorInfo
+		if json.Unmarshal(raw, &info) == nil && strings.HasSuffix(info.Type, "google.rpc.ErrorInfo") {
+			return info
+		}
+	}
+	return errorInfo{}
+}
+
+// classifyError returns err classified, or nil when it is of no known kind.
+// The hints name projectID when the error does not tell the project.
+func (h *handlers) classifyError(err error, projectID string) *ToolError {
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		return toolErr
+	}
+	account := h.c.GetAccount()
+	if account == "" {
+		account = "ACCOUNT"
+	}
+	if projectID == "" {
+		projectID = "PROJECT"
+	}
+
+	var apiErr *genericCore.APIError
+	if errors.As(err, &apiErr) {
+		if p := ProjectOf(apiErr.URL); p != "" {
+			projectID = p
+		}
+		host := ""
+		if u, err := url.Parse(apiErr.URL); err == nil {
+			host = u.Host
+		}
+		info := errorInfoOf(apiErr)
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized:
+			return authExpired(err)
+		case info.Reason == "SERVICE_DISABLED" || info.Reason == "ACCESS_NOT_CONFIGURED" ||
+			strings.Contains(apiErr.Message, "has not been used in project") || strings.Contains(apiErr.Message, "it is disabled"):
+			service := info.Metadata["service"]
+			if service == "" {
+				service = host
+			}
+			return &ToolError{Kind: ErrorAPIDisabled, Err: err,
+				Hint: fmt.Sprintf("Enable the API in the project: `gcloud services enable %s --project=%s`, then retry after a minute.", service, projectID)}
+		case apiErr.StatusCode == http.StatusForbidden:
+			return permissionDenied(err, host, info.Metadata["permission"], projectID, account)
+		case apiErr.StatusCode == http.StatusNotFound:
+			return notFound(err, projectID)
+		case apiErr.StatusCode == http.StatusGatewayTimeout || apiErr.StatusCode == http.StatusRequestTimeout:
+			return timedOut(err)
+		}
+	}
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return notFound(err, projectID)
+	case errors.Is(err, remote.ErrIAPDenied):
+		return &ToolError{Kind: ErrorIAPDenied, Err: err,
+			Hint: fmt.Sprintf("Grant the IAP-secured Tunnel User role: `gcloud projects add-iam-policy-binding %s --member=user:%s --role=roles/iap.tunnelResourceAccessor`, "+
+				"and let IAP reach the login nodes: `gcloud compute firewall-rules create allow-iap-ssh --project=%s --network=NETWORK --direction=INGRESS --allow=tcp:22 --source-ranges=35.235.240.0/20`. "+
+				"Alternatively use slurm_backend=rest, which needs no SSH access.", projectID, account, projectID)}
+	case errors.Is(err, auth.ErrNoAccessToken):
+		return authExpired(err)
+	case errors.Is(err, errOperationTimeout), errors.Is(err, context.DeadlineExceeded):
+		return timedOut(err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return timedOut(err)
+	}
+	return nil
+}
+
+func notFound(err error, projectID string) *ToolError {
+	return &ToolError{Kind: ErrorNotFound, Err: err,
+		Hint: fmt.Sprintf("Check the name and project. list_clusters and list_operations show what exists in project %s; use_profile switches to another project.", projectID)}
+}
+
+func authExpired(err error) *ToolError {
+	return &ToolError{Kind: ErrorAuthExpired, Err: err,
+		Hint: "The Google Cloud credentials are missing or expired. Run `gcloud auth application-default login`, or point credentials_file at a valid service account key."}
+}
+
+func timedOut(err error) *ToolError {
+	return &ToolError{Kind: ErrorTimeout, Err: err,
+		Hint: "The call did not finish in time. Retry it; long-running operations keep going, follow them with wait_operation or raise timeout_minutes."}
+}
+
+// sshOnly returns the error of a Slurm feature, described by what, that
+// slurmrestd does not offer.
+func sshOnly(what string) *ToolError {
+	return &ToolError{Kind: ErrorSSHOnly, Err: fmt.Errorf("%s is only available over ssh", what),
+		Hint: "slurmrestd does not offer it. Retry with slurm_backend=ssh, or with slurm_backend=auto, which runs it over ssh."}
+}
+
+// permissionDenied returns the error of a call to host that account may not
+// make, naming the missing permission if the API told it.
+func permissionDenied(err error, host string, permission string, projectID string, account string) *ToolError {
+	role := "ROLE"
+	if strings.HasPrefix(host, "oslogin.") {
+		role = "roles/compute.osAdminLogin"
+	}
+	hint := fmt.Sprintf("The account %s lacks permission", account)
+	if permission != "" {
+		hint += " " + permission
+	}
+	hint += fmt.Sprintf(" in project %s. Ask a project owner to grant a role that has it: `gcloud projects add-iam-policy-binding %s --member=user:%s --role=%s`.",
+		projectID, projectID, account, role)
+	return &ToolError{Kind: ErrorPermissionDenied, Err: err, Hint: hint}
+}
+
+// explainError renders err for tool output, followed by its kind and the
+// hint on how to fix it when it is of a known kind. projectID is the project
+// the tool worked on.
+func (h *handlers) explainError(err error, projectID string) string {
+	toolErr := h.classifyError(err, projectID)
+	if toolErr == nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("%v\nKind: %s\nHint: %s", err, toolErr.Kind, toolErr.Hint)
+}
+
+// toolError returns the tool result reporting err as explainError does.
+func (h *handlers) toolError(err error, projectID string) *mcp.CallToolResult {
+	return mcp.NewToolResultError(h.explainError(err, projectID))
+}
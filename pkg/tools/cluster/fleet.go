@@ -0,0 +1,274 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// projectIDPattern matches project IDs, including domain-scoped ones
+	// such as example.com:my-project.
+	projectIDPattern = regexp.MustCompile(`^([a-z0-9.-]+:)?[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+	folderPattern    = regexp.MustCompile(`^(folders/)?[0-9]{1,32}$`)
+	labelKeyPattern  = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	// labelValuePattern also matches *, which selects any value.
+	labelValuePattern = regexp.MustCompile(`^([a-z0-9_-]{0,63}|\*)$`)
+)
+
+// FleetSelector chooses the projects list_clusters covers. The projects are
+// the union of Projects and those found under Folder with all of Labels.
+type FleetSelector struct {
+	Projects []string
+	// Folder is a folder ID, with or without the folders/ prefix.
+	Folder string
+	// Labels are key=value pairs, or keys alone to match any value.
+	Labels []string
+}
+
+func (s FleetSelector) empty() bool {
+	return len(s.Projects) == 0 && s.Folder == "" && len(s.Labels) == 0
+}
+
+// query returns the projects.search query for Folder and Labels, or "" when
+// neither is set.
+func (s FleetSelector) query() (string, error) {
+	var terms []string
+	if s.Folder != "" {
+		if !folderPattern.MatchString(s.Folder) {
+			return "", fmt.Errorf("invalid folder %q, want a numeric folder ID", s.Folder)
+		}
+		terms = append(terms, "parent:folders/"+strings.TrimPrefix(s.Folder, "folders/"))
+	}
+	for _, label := range s.Labels {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok {
+			value = "*"
+		}
+		if !labelKeyPattern.MatchString(key) || !labelValuePattern.MatchString(value) {
+			return "", fmt.Errorf("invalid label selector %q, want key=value", label)
+		}
+		terms = append(terms, fmt.Sprintf("labels.%s:%s", key, value))
+	}
+	if len(terms) == 0 {
+		return "", nil
+	}
+	return strings.Join(append(terms, "state:ACTIVE"), " "), nil
+}
+
+// commaList splits a comma separated list, dropping empty entries.
+func commaList(v string) []string {
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// resolveProjects returns the projects sel selects, or the default project
+// when sel is empty.
+func (h *handlers) resolveProjects(ctx context.Context, sel FleetSelector) ([]string, error) {
+	if sel.empty() {
+		if p := h.c.GetDefaultProjectID(); p != "" {
+			return []string{p}, nil
+		}
+		return nil, fmt.Errorf("no project given and no default project configured")
+	}
+	for _, p := range sel.Projects {
+		if !projectIDPattern.MatchString(p) {
+			return nil, fmt.Errorf("invalid project ID %q", p)
+		}
+	}
+	query, err := sel.query()
+	if err != nil {
+		return nil, err
+	}
+
+	projects := append([]string(nil), sel.Projects...)
+	if query != "" {
+		found, err := h.api.SearchProjects(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		slog.DebugContext(ctx, "Resolved projects", "query", query, "projects", found)
+		projects = append(projects, found...)
+	}
+	sort.Strings(projects)
+	unique := projects[:0]
+	for i, p := range projects {
+		if i == 0 || p != projects[i-1] {
+			unique = append(unique, p)
+		}
+	}
+	return unique, nil
+}
+
+// FleetCluster is a row of the list_clusters table.
+type FleetCluster struct {
+	Project     string `json:"project"`
+	Region      string `json:"region"`
+	Name        string `json:"name"`
+	CreateTime  string `json:"createTime,omitempty"`
+	Reconciling bool   `json:"reconciling"`
+}
+
+// FleetError reports a project, or a region of one, whose clusters could not
+// be listed.
+type FleetError struct {
+	Project string    `json:"project"`
+	Region  string    `json:"region,omitempty"`
+	Error   string    `json:"error"`
+	Kind    ErrorKind `json:"kind,omitempty"`
+	Hint    string    `json:"hint,omitempty"`
+}
+
+// fleetError returns the FleetError of err, classified when it is of a known
+// kind.
+func (h *handlers) fleetError(project string, region string, err error) FleetError {
+	f := FleetError{Project: project, Region: region, Error: err.Error()}
+	if toolErr := h.classifyError(err, project); toolErr != nil {
+		f.Kind, f.Hint = toolErr.Kind, toolErr.Hint
+	}
+	return f
+}
+
+// FleetListing is the output of list_clusters. Clusters are sorted by
+// project, region and name.
+type FleetListing struct {
+	Projects []string       `json:"projects"`
+	Clusters []FleetCluster `json:"clusters"`
+	Errors   []FleetError   `json:"errors,omitempty"`
+	// CacheAge is how long ago the oldest of the listings was fetched.
+	CacheAge string `json:"cacheAge"`
+	// NextPageToken is set when more clusters follow this page.
+	NextPageToken string `json:"nextPageToken,omitempty"`
+}
+
+// fleetLess orders the rows of list_clusters.
+func fleetLess(a, b FleetCluster) bool {
+	if a.Project != b.Project {
+		return a.Project < b.Project
+	}
+	if a.Region != b.Region {
+		return a.Region < b.Region
+	}
+	return a.Name < b.Name
+}
+
+// fleetPageToken returns the token of the page of list_clusters that starts
+// after c in the listing kept as snapshot.
+func fleetPageToken(snapshot string, c FleetCluster) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(snapshot + "/" + c.Project + "/" + c.Region + "/" + c.Name))
+}
+
+// parseFleetPageToken returns the snapshot and the last cluster of the
+// previous page that pageToken was made from.
+func parseFleetPageToken(pageToken string) (string, FleetCluster, error) {
+	key, err := base64.RawURLEncoding.DecodeString(pageToken)
+	parts := strings.SplitN(string(key), "/", 4)
+	if err != nil || len(parts) != 4 {
+		return "", FleetCluster{}, fmt.Errorf("invalid page_token %q, pass the nextPageToken of the previous call", pageToken)
+	}
+	return parts[0], FleetCluster{Project: parts[1], Region: parts[2], Name: parts[3]}, nil
+}
+
+// newFleetSnapshot returns a new ID for a listing kept for its later pages.
+func newFleetSnapshot() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// paginate cuts the clusters of l down to the pageSize ones, all of them if
+// 0, that follow after, or start from the first when after is nil. When more
+// follow, it sets NextPageToken to read them from the listing kept as
+// snapshot.
+func (l *FleetListing) paginate(pageSize int, after *FleetCluster, snapshot string) error {
+	if pageSize < 0 {
+		return fmt.Errorf("invalid page_size %d, want 0 or more", pageSize)
+	}
+	if after != nil {
+		start := sort.Search(len(l.Clusters), func(i int) bool { return fleetLess(*after, l.Clusters[i]) })
+		l.Clusters = l.Clusters[start:]
+	}
+	if pageSize > 0 && len(l.Clusters) > pageSize {
+		l.Clusters = l.Clusters[:pageSize]
+		l.NextPageToken = fleetPageToken(snapshot, l.Clusters[pageSize-1])
+	}
+	return nil
+}
+
+// listFleet lists the clusters in every region of projects, fanning out over
+// projects and regions. Projects and regions that cannot be listed are
+// reported in the errors of the listing rather than failing it. Cached
+// listings are used unless refresh is set.
+func (h *handlers) listFleet(ctx context.Context, projects []string, refresh bool) FleetListing {
+	listing := FleetListing{Projects: projects, Clusters: []FleetCluster{}}
+
+	regions := make([][]string, len(projects))
+	errs := fanOut(ctx, len(projects), h.fanOut, func(ctx context.Context, i int) error {
+		found, _, err := h.regionsAndZones(ctx, projects[i], false)
+		regions[i] = sortedRegions(found)
+		return err
+	})
+
+	var targets []regionTarget
+	for i, project := range projects {
+		if errs[i] != nil {
+			listing.Errors = append(listing.Errors, h.fleetError(project, "", errs[i]))
+			continue
+		}
+		for _, region := range regions[i] {
+			targets = append(targets, regionTarget{project: project, region: region})
+		}
+	}
+	clusters, fetched, failed := h.listClustersIn(ctx, targets, refresh)
+	listing.Errors = append(listing.Errors, failed...)
+	oldest := time.Now()
+	for i, t := range targets {
+		if clusters[i] == nil {
+			continue
+		}
+		if fetched[i].Before(oldest) {
+			oldest = fetched[i]
+		}
+		for _, c := range clusters[i] {
+			listing.Clusters = append(listing.Clusters, FleetCluster{
+				Project:     t.project,
+				Region:      t.region,
+				Name:        ShortName(c.Name),
+				CreateTime:  c.CreateTime,
+				Reconciling: c.Reconciling,
+			})
+		}
+	}
+	listing.CacheAge = cacheAge(oldest)
+
+	sort.Slice(listing.Clusters, func(i, j int) bool {
+		return fleetLess(listing.Clusters[i], listing.Clusters[j])
+	})
+	return listing
+}
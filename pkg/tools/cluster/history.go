@@ -0,0 +1,363 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/remote"
+)
+
+// sacctFormat is the list of fields requested from sacct. JobName comes last
+// as it is the only field that may contain the separator.
+const sacctFormat = "JobID,User,Partition,State,Elapsed,ElapsedRaw,ExitCode,MaxRSS,AllocTRES,NNodes,Start,End,JobName"
+
+// sacctFields is the number of fields in sacctFormat.
+const sacctFields = 13
+
+var (
+	// sacctTimePattern matches the times accepted by sacct, e.g. now-1days
+	// or 2025-06-30T08:00.
+	sacctTimePattern = regexp.MustCompile(`^[A-Za-z0-9:+-]{1,32}$`)
+	// slurmNamePattern matches Slurm user, partition and state names.
+	slurmNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+)
+
+// failedJobStates are the final job states that count as failures.
+var failedJobStates = map[string]bool{
+	"BOOT_FAIL":     true,
+	"DEADLINE":      true,
+	"FAILED":        true,
+	"NODE_FAIL":     true,
+	"OUT_OF_MEMORY": true,
+	"TIMEOUT":       true,
+}
+
+// HistoryQuery selects the jobs reported by job_history.
+type HistoryQuery struct {
+	// Since and Until bound the time window in any format accepted by sacct.
+	Since string
+	Until string
+	// User, Partition and State are empty to select every job.
+	User      string
+	Partition string
+	State     string
+}
+
+// AccountingRecord is a finished or running job as recorded by Slurm
+// accounting.
+type AccountingRecord struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	User           string `json:"user"`
+	Partition      string `json:"partition"`
+	State          string `json:"state"`
+	Elapsed        string `json:"elapsed"`
+	ElapsedSeconds int64  `json:"elapsedSeconds"`
+	ExitCode       string `json:"exitCode"`
+	// MaxRSS is the largest resident set size of any step of the job.
+	MaxRSS      string  `json:"maxRss,omitempty"`
+	MaxRSSBytes int64   `json:"maxRssBytes,omitempty"`
+	AllocTRES   string  `json:"allocTres,omitempty"`
+	GPUs        int64   `json:"gpus"`
+	GPUHours    float64 `json:"gpuHours"`
+	Nodes       int     `json:"nodes"`
+	Start       string  `json:"start,omitempty"`
+	End         string  `json:"end,omitempty"`
+}
+
+// Failed reports whether the job ended in a failure state.
+func (r AccountingRecord) Failed() bool {
+	return failedJobStates[r.State]
+}
+
+// validate checks the fields of q that are passed to sacct.
+func (q HistoryQuery) validate() error {
+	for _, f := range []struct{ name, value string }{{"since", q.Since}, {"until", q.Until}} {
+		if !sacctTimePattern.MatchString(f.value) {
+			return fmt.Errorf("%s %q is not a sacct time such as now-1days, 2025-06-30 or 2025-06-30T08:00", f.name, f.value)
+		}
+	}
+	for _, f := range []struct{ name, value string }{{"user", q.User}, {"partition", q.Partition}, {"state", q.State}} {
+		if f.value != "" && !slurmNamePattern.MatchString(f.value) {
+			return fmt.Errorf("%s %q is not a valid Slurm name", f.name, f.value)
+		}
+	}
+	return nil
+}
+
+// sacctCommand returns the sacct command for q.
+func sacctCommand(q HistoryQuery) remote.Command {
+	args := []string{
+		"--noheader",
+		"--parsable2",
+		"--starttime=" + q.Since,
+		"--endtime=" + q.Until,
+		"--format=" + sacctFormat,
+	}
+	if q.User != "" {
+		args = append(args, "--user="+q.User)
+	} else {
+		args = append(args, "--allusers")
+	}
+	if q.Partition != "" {
+		args = append(args, "--partition="+q.Partition)
+	}
+	if q.State != "" {
+		args = append(args, "--state="+q.State)
+	}
+	return remote.Command{Program: "sacct", Args: args}
+}
+
+// parseSacct parses the output of sacctCommand. Job steps are folded into
+// their job, contributing their MaxRSS.
+func parseSacct(output string) ([]AccountingRecord, error) {
+	var records []AccountingRecord
+	index := make(map[string]int)
+	for i, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "|", sacctFields)
+		if len(fields) != sacctFields {
+			return nil, fmt.Errorf("line %d of sacct output has %d fields, want %d: %q", i+1, len(fields), sacctFields, line)
+		}
+		id := fields[0]
+		maxRSS := parseSlurmSize(fields[7])
+
+		if jobID, _, isStep := strings.Cut(id, "."); isStep {
+			if j, ok := index[jobID]; ok && maxRSS > records[j].MaxRSSBytes {
+				records[j].MaxRSSBytes = maxRSS
+				records[j].MaxRSS = fields[7]
+			}
+			continue
+		}
+
+		elapsed, err := strconv.ParseInt(fields[5], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of sacct output has invalid ElapsedRaw %q", i+1, fields[5])
+		}
+		nodes, _ := strconv.Atoi(fields[9])
+		state, _, _ := strings.Cut(fields[3], " ")
+		gpus := gpusInTRES(fields[8])
+		r := AccountingRecord{
+			ID:             id,
+			User:           fields[1],
+			Partition:      fields[2],
+			State:          state,
+			Elapsed:        fields[4],
+			ElapsedSeconds: elapsed,
+			ExitCode:       fields[6],
+			AllocTRES:      fields[8],
+			GPUs:           gpus,
+			GPUHours:       float64(gpus) * float64(elapsed) / 3600,
+			Nodes:          nodes,
+			Start:          slurmOptional(fields[10]),
+			End:            slurmOptional(fields[11]),
+			Name:           fields[12],
+		}
+		if maxRSS > 0 {
+			r.MaxRSS, r.MaxRSSBytes = fields[7], maxRSS
+		}
+		index[id] = len(records)
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// gpusInTRES returns the number of GPUs in a TRES list such as
+// "cpu=8,gres/gpu=8,gres/gpu:a100=8,mem=32G,node=1". Typed counts are only
+// used when the total is missing.
+func gpusInTRES(tres string) int64 {
+	var total, typed int64
+	for _, item := range strings.Split(tres, ",") {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch {
+		case name == "gres/gpu":
+			total += n
+		case strings.HasPrefix(name, "gres/gpu:"):
+			typed += n
+		}
+	}
+	if total > 0 {
+		return total
+	}
+	return typed
+}
+
+// parseSlurmSize returns the number of bytes of a Slurm size such as 1234K or
+// 1.5G, or 0 if it is empty or invalid.
+func parseSlurmSize(size string) int64 {
+	if size == "" {
+		return 0
+	}
+	multiplier := float64(1)
+	switch size[len(size)-1] {
+	case 'K':
+		multiplier = 1 << 10
+	case 'M':
+		multiplier = 1 << 20
+	case 'G':
+		multiplier = 1 << 30
+	case 'T':
+		multiplier = 1 << 40
+	}
+	if multiplier > 1 {
+		size = size[:len(size)-1]
+	}
+	n, err := strconv.ParseFloat(size, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(n * multiplier)
+}
+
+// JobHistory is the result of job_history.
+type JobHistory struct {
+	Since          string             `json:"since"`
+	Until          string             `json:"until"`
+	Total          int                `json:"total"`
+	ByState        map[string]int     `json:"byState"`
+	Failures       int                `json:"failures"`
+	FailedJobs     []AccountingRecord `json:"failedJobs"`
+	GPUHours       float64            `json:"gpuHours"`
+	GPUHoursByUser map[string]float64 `json:"gpuHoursByUser"`
+	// Jobs lists at most max_results of the jobs.
+	Jobs []AccountingRecord `json:"jobs"`
+}
+
+// newJobHistory summarises records, listing at most limit of them.
+func newJobHistory(q HistoryQuery, records []AccountingRecord, limit int) JobHistory {
+	h := JobHistory{
+		Since:          q.Since,
+		Until:          q.Until,
+		Total:          len(records),
+		ByState:        make(map[string]int),
+		FailedJobs:     []AccountingRecord{},
+		GPUHoursByUser: make(map[string]float64),
+		Jobs:           []AccountingRecord{},
+	}
+	for _, r := range records {
+		h.ByState[r.State]++
+		if r.Failed() {
+			h.Failures++
+			if limit <= 0 || len(h.FailedJobs) < limit {
+				h.FailedJobs = append(h.FailedJobs, r)
+			}
+		}
+		h.GPUHours += r.GPUHours
+		if r.GPUHours > 0 {
+			h.GPUHoursByUser[r.User] += r.GPUHours
+		}
+		if limit <= 0 || len(h.Jobs) < limit {
+			h.Jobs = append(h.Jobs, r)
+		}
+	}
+	return h
+}
+
+// Summary renders h for people.
+func (h JobHistory) Summary() string {
+	var b strings.Builder
+	if h.Total == 0 {
+		fmt.Fprintf(&b, "No jobs between %s and %s.\n", h.Since, h.Until)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%d job(s) between %s and %s: %s\n", h.Total, h.Since, h.Until, formatCounts(h.ByState))
+	if h.Failures > 0 {
+		fmt.Fprintf(&b, "%d failed job(s):\n", h.Failures)
+		for _, r := range h.FailedJobs {
+			fmt.Fprintf(&b, "  %s %s (%s) %s, exit code %s after %s", r.ID, r.Name, r.User, r.State, r.ExitCode, r.Elapsed)
+			if r.MaxRSS != "" {
+				fmt.Fprintf(&b, ", MaxRSS %s", r.MaxRSS)
+			}
+			b.WriteString("\n")
+		}
+	} else {
+		b.WriteString("No failed jobs.\n")
+	}
+	if h.GPUHours > 0 {
+		users := make([]string, 0, len(h.GPUHoursByUser))
+		for user := range h.GPUHoursByUser {
+			users = append(users, user)
+		}
+		sort.Slice(users, func(i, j int) bool { return h.GPUHoursByUser[users[i]] > h.GPUHoursByUser[users[j]] })
+		parts := make([]string, len(users))
+		for i, user := range users {
+			parts[i] = fmt.Sprintf("%s %.1f", user, h.GPUHoursByUser[user])
+		}
+		fmt.Fprintf(&b, "GPU-hours: %.1f (%s)\n", h.GPUHours, strings.Join(parts, ", "))
+	}
+	if len(h.Jobs) < h.Total {
+		fmt.Fprintf(&b, "Listing the first %d job(s).\n", len(h.Jobs))
+	}
+	return b.String()
+}
+
+func (b *sshSlurmBackend) History(ctx context.Context, q HistoryQuery) ([]AccountingRecord, error) {
+	output, err := b.exec(ctx, sacctCommand(q))
+	if err != nil {
+		return nil, err
+	}
+	return parseSacct(output)
+}
+
+func (b *restSlurmBackend) History(ctx context.Context, q HistoryQuery) ([]AccountingRecord, error) {
+	return nil, sshOnly("job history")
+}
+
+func (h *handlers) jobHistory(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	t, err := h.slurmTargetFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	q := HistoryQuery{
+		Since:     request.GetString("since", "now-1days"),
+		Until:     request.GetString("until", "now"),
+		User:      request.GetString("user", ""),
+		Partition: request.GetString("partition", ""),
+		State:     request.GetString("state", ""),
+	}
+	if err := q.validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	limit := request.GetInt("max_results", defaultMaxJobs)
+	if t.backend == SlurmBackendAuto {
+		// slurmrestd has no sacct equivalent
+		t.backend = SlurmBackendSSH
+	}
+
+	return h.runSlurm(ctx, t, "history", func(b slurmBackend) (interface{}, error) {
+		records, err := b.History(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		return newJobHistory(q, records, limit), nil
+	}), nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/remote"
+)
+
+// Job control actions.
+const (
+	JobActionCancel  = "cancel"
+	JobActionHold    = "hold"
+	JobActionRelease = "release"
+	JobActionRequeue = "requeue"
+)
+
+// jobActionCommands are the Slurm commands run on the login node for every job
+// control action. The job ID is appended.
+var jobActionCommands = map[string]remote.Command{
+	JobActionCancel:  {Program: "scancel"},
+	JobActionHold:    {Program: "scontrol", Args: []string{"hold"}},
+	JobActionRelease: {Program: "scontrol", Args: []string{"release"}},
+	JobActionRequeue: {Program: "scontrol", Args: []string{"requeue"}},
+}
+
+// jobActionPastTense is used to report the action taken.
+var jobActionPastTense = map[string]string{
+	JobActionCancel:  "Cancelled",
+	JobActionHold:    "Held",
+	JobActionRelease: "Released",
+	JobActionRequeue: "Requeued",
+}
+
+var jobIDPattern = regexp.MustCompile(`^[0-9]{1,12}$`)
+
+// JobControlResult is the result of a job control tool.
+type JobControlResult struct {
+	JobID  string `json:"jobId"`
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	User   string `json:"user"`
+	Before string `json:"stateBefore"`
+	// After is the state of the job once the action was taken, or empty if
+	// the job has left the queue.
+	After  string `json:"stateAfter,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Summary renders r for people.
+func (r JobControlResult) Summary() string {
+	msg := fmt.Sprintf("%s job %s (%s, user %s). ", jobActionPastTense[r.Action], r.JobID, r.Name, r.User)
+	if r.After == "" {
+		return msg + fmt.Sprintf("It was %s and is no longer in the queue.\n", r.Before)
+	}
+	msg += fmt.Sprintf("State: %s -> %s", r.Before, r.After)
+	if r.Reason != "" {
+		msg += ", reason " + r.Reason
+	}
+	return msg + "\n"
+}
+
+// findJob returns the job with ID id in the queue of b, or nil if there is
+// none.
+func findJob(ctx context.Context, b slurmBackend, id string) (*JobRecord, error) {
+	jobs, err := b.Jobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		if jobs[i].ID == id {
+			return &jobs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (b *sshSlurmBackend) CurrentUser(ctx context.Context) (string, error) {
+	output, err := b.exec(ctx, remote.Command{Program: "id", Args: []string{"-un"}})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+func (b *sshSlurmBackend) ControlJob(ctx context.Context, action string, jobID string) error {
+	cmd, ok := jobActionCommands[action]
+	if !ok {
+		return fmt.Errorf("unknown job action %q", action)
+	}
+	cmd.Args = append(append([]string{}, cmd.Args...), jobID)
+	_, err := b.exec(ctx, cmd)
+	return err
+}
+
+func (b *restSlurmBackend) CurrentUser(ctx context.Context) (string, error) {
+	var resp struct {
+		Meta struct {
+			Client struct {
+				User string `json:"user"`
+			} `json:"client"`
+		} `json:"meta"`
+	}
+	if err := b.get(ctx, "ping", &resp); err != nil {
+		return "", err
+	}
+	if resp.Meta.Client.User == "" {
+		return "", fmt.Errorf("slurmrestd did not report the user")
+	}
+	return resp.Meta.Client.User, nil
+}
+
+func (b *restSlurmBackend) ControlJob(ctx context.Context, action string, jobID string) error {
+	var resp struct{}
+	switch action {
+	case JobActionCancel:
+		return b.call(ctx, http.MethodDelete, "job/"+jobID, nil, &resp)
+	case JobActionHold, JobActionRelease:
+		return b.call(ctx, http.MethodPost, "job/"+jobID, map[string]interface{}{
+			"hold": action == JobActionHold,
+		}, &resp)
+	}
+	return sshOnly(action)
+}
+
+// controlJob returns the handler of the job control tool for action.
+func (h *handlers) controlJob(action string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		t, err := h.slurmTargetFromRequest(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		jobID, err := request.RequireString("job_id")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if !jobIDPattern.MatchString(jobID) {
+			return mcp.NewToolResultError(fmt.Sprintf("job_id %q must be a numeric Slurm job ID", jobID)), nil
+		}
+		allowOtherUsers := request.GetBool("allow_other_users", false)
+
+		if action == JobActionRequeue && t.backend == SlurmBackendAuto {
+			// slurmrestd cannot requeue jobs
+			t.backend = SlurmBackendSSH
+		}
+
+		return h.runSlurm(ctx, t, "result", func(b slurmBackend) (interface{}, error) {
+			job, err := findJob(ctx, b, jobID)
+			if err != nil {
+				return nil, err
+			}
+			if job == nil {
+				return nil, fmt.Errorf("job %s is not in the queue of cluster %s", jobID, t.clusterName)
+			}
+			if !allowOtherUsers {
+				me, err := b.CurrentUser(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("could not check who owns job %s: %w", jobID, err)
+				}
+				if job.User != me {
+					return nil, fmt.Errorf("job %s belongs to %s, not %s. Only set allow_other_users if the user explicitly asked to %s another user's job",
+						jobID, job.User, me, action)
+				}
+			}
+			if err := b.ControlJob(ctx, action, jobID); err != nil {
+				return nil, slurmChange(err)
+			}
+			slog.InfoContext(ctx, jobActionPastTense[action]+" job", "job", jobID, "cluster", t.clusterName)
+
+			result := JobControlResult{JobID: jobID, Action: action, Name: job.Name, User: job.User, Before: job.State}
+			after, err := findJob(ctx, b, jobID)
+			if err != nil {
+				result.After = "UNKNOWN"
+				result.Reason = err.Error()
+			} else if after != nil {
+				result.After = after.State
+				result.Reason = after.Reason
+			}
+			return result, nil
+		}), nil
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
+)
+
+// logToolCall returns handler, the handler of the tool called name, logging
+// every call and its outcome. The records logged while the call runs carry
+// the tool name and an ID of the call.
+func logToolCall(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx = genericCore.WithLogAttrs(ctx, slog.String("tool", name), slog.String("call_id", genericCore.NewCorrelationID()))
+		slog.DebugContext(ctx, "Tool called", "arguments", request.GetArguments())
+		start := time.Now()
+
+		result, err := handler(ctx, request)
+		elapsed := slog.Duration("duration", time.Since(start))
+		switch {
+		case err != nil:
+			slog.ErrorContext(ctx, "Tool call failed", elapsed, "error", err)
+		case result != nil && result.IsError:
+			slog.WarnContext(ctx, "Tool call returned an error", elapsed, "error", resultText(result))
+		default:
+			slog.InfoContext(ctx, "Tool call succeeded", elapsed)
+		}
+		return result, err
+	}
+}
+
+// resultText returns the text of the first text content of result.
+func resultText(result *mcp.CallToolResult) string {
+	for _, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok {
+			return text.Text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,213 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// nodeSetsUpdateMask is the update mask for changes to Slurm node sets.
+const nodeSetsUpdateMask = "orchestrator.slurm.nodeSets"
+
+// nodeSetSize summarises the capacity of a node set.
+type nodeSetSize struct {
+	Nodes            int64
+	MachineType      string
+	Accelerators     int64
+	AcceleratorTypes []string
+}
+
+func (s nodeSetSize) String() string {
+	if s.Accelerators == 0 {
+		return fmt.Sprintf("%d x %s", s.Nodes, s.MachineType)
+	}
+	return fmt.Sprintf("%d x %s, %d accelerator(s) (%s)", s.Nodes, s.MachineType, s.Accelerators, strings.Join(s.AcceleratorTypes, ", "))
+}
+
+// acceleratorsPerNode returns the number and types of the accelerators attached
+// to every VM of rr.
+func acceleratorsPerNode(rr ResourceRequest) (int64, []string) {
+	var total int64
+	var types []string
+	for _, acc := range rr.GuestAccelerators {
+		if len(acc) == 0 {
+			continue
+		}
+		count, accType := parseAccelerator(acc)
+		total += count
+		types = append(types, accType)
+	}
+	return total, types
+}
+
+// parseAccelerator returns the count and short type name of a guestAccelerators
+// entry. The count defaults to 1 and may be encoded as a number or a string.
+func parseAccelerator(acc map[string]interface{}) (int64, string) {
+	count := int64(1)
+	if v, ok := acc["count"]; ok {
+		if n, err := strconv.ParseInt(fmt.Sprint(v), 10, 64); err == nil {
+			count = n
+		}
+	}
+	accType, _ := acc["acceleratorType"].(string)
+	if accType == "" {
+		accType, _ = acc["type"].(string)
+	}
+	return count, ShortName(accType)
+}
+
+// sizeOfNodeSet returns the capacity of ns running nodes static nodes in
+// cluster.
+func sizeOfNodeSet(cluster *Cluster, ns NodeSet, nodes int64) nodeSetSize {
+	size := nodeSetSize{Nodes: nodes}
+	for _, rr := range cluster.Compute.ResourceRequests {
+		if rr.ID == ns.ResourceRequestID {
+			size.MachineType = rr.MachineType
+			perNode, types := acceleratorsPerNode(rr)
+			size.Accelerators = perNode * nodes
+			size.AcceleratorTypes = types
+		}
+	}
+	return size
+}
+
+// waitForReconcile polls the cluster called name until the service has
+// finished reconciling it, a poll fails with an error that is not transient,
+// timeout elapses or ctx is cancelled.
+func (h *handlers) waitForReconcile(ctx context.Context, name string, timeout time.Duration) (*Cluster, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(h.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		cluster, err := h.api.GetCluster(ctx, name)
+		if err != nil {
+			if !transientError(err) {
+				return nil, fmt.Errorf("could not poll cluster %s: %w", name, err)
+			}
+			slog.WarnContext(ctx, "Could not poll cluster", "cluster", name, "error", err)
+		} else if !cluster.Reconciling {
+			return cluster, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("cluster %s is still reconciling after %s", name, timeout)
+			}
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *handlers) updateNodeSetSize(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	projectID := request.GetString("project_id", h.c.GetDefaultProjectID())
+	if projectID == "" {
+		return mcp.NewToolResultError("project_id argument not set"), nil
+	}
+	clusterName, err := request.RequireString("clusterName")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	nodeSetID, err := request.RequireString("nodeset_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	newCount, err := request.RequireInt("static_node_count")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if newCount < 0 {
+		return mcp.NewToolResultError("static_node_count must not be negative"), nil
+	}
+	timeout, err := operationTimeout(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	cluster, _, err := h.findCluster(ctx, projectID, clusterName, true)
+	if err != nil {
+		return h.toolError(err, projectID), nil
+	}
+	if cluster.Reconciling {
+		return mcp.NewToolResultError(fmt.Sprintf("cluster %s is already being updated, wait until it has finished reconciling", clusterName)), nil
+	}
+
+	idx := -1
+	var ids []string
+	for i, ns := range cluster.Orchestrator.Slurm.NodeSets {
+		ids = append(ids, ns.ID)
+		if ns.ID == nodeSetID {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("cluster %s has no node set %q, its node sets are: %s",
+			clusterName, nodeSetID, strings.Join(ids, ", "))), nil
+	}
+
+	ns := cluster.Orchestrator.Slurm.NodeSets[idx]
+	oldCount, err := parseCount(ns.StaticNodeCount)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("node set %s has invalid staticNodeCount %q", nodeSetID, ns.StaticNodeCount)), nil
+	}
+	before := sizeOfNodeSet(cluster, ns, oldCount)
+	after := sizeOfNodeSet(cluster, ns, int64(newCount))
+	summary := fmt.Sprintf("Node set %s of cluster %s\n  before: %s\n  after:  %s\n", nodeSetID, clusterName, before, after)
+	if oldCount == int64(newCount) {
+		return mcp.NewToolResultText(summary + "Nothing to do, the node set already has this size."), nil
+	}
+
+	updated := *cluster
+	updated.Orchestrator.Slurm.NodeSets = append([]NodeSet(nil), cluster.Orchestrator.Slurm.NodeSets...)
+	updated.Orchestrator.Slurm.NodeSets[idx].StaticNodeCount = strconv.Itoa(newCount)
+
+	op, err := h.api.UpdateCluster(ctx, &updated, []string{nodeSetsUpdateMask})
+	if err != nil {
+		slog.WarnContext(ctx, "Could not update cluster", "cluster", cluster.Name, "error", err)
+		return h.toolError(fmt.Errorf("could not update cluster %s: %w", cluster.Name, err), projectID), nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	started := op
+	if op, err = h.waitForOperation(ctx, started.Name, timeout, operationProgress(ctx, request)); err != nil {
+		if op == nil {
+			op = started
+		}
+		return mcp.NewToolResultError(fmt.Sprintf("%sThe update was started but has not finished: %s\n%s", summary, h.explainError(err, projectID), describeOperation(op))), nil
+	}
+	if op.Error != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("%sThe update failed.\n%s", summary, describeOperation(op))), nil
+	}
+
+	latest, err := h.waitForReconcile(ctx, cluster.Name, time.Until(deadline))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("%sThe update operation finished but %s", summary, h.explainError(err, projectID))), nil
+	}
+	h.forgetClusterState(cluster.Name)
+	h.clusters.set(cluster.Name, *latest)
+	return mcp.NewToolResultText(summary + "The node set was resized and the cluster has finished reconciling."), nil
+}
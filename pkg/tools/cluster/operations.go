@@ -0,0 +1,286 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/auth"
+	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
+)
+
+const (
+	defaultOperationTimeout      = 30 * time.Minute
+	defaultOperationPollInterval = 10 * time.Second
+)
+
+// errOperationTimeout is returned by waitForOperation when the operation is
+// still running at the deadline.
+var errOperationTimeout = errors.New("timed out waiting for operation")
+
+// waitForOperation polls the operation called name until it is done, a poll
+// fails with an error that is not transient, timeout elapses or ctx is
+// cancelled. onPoll, if not nil, is called with every state
+// of the operation seen. It returns the last state of the operation seen,
+// which is non-nil whenever at least one poll succeeded.
+func (h *handlers) waitForOperation(ctx context.Context, name string, timeout time.Duration, onPoll func(op *Operation)) (*Operation, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(h.pollInterval)
+	defer ticker.Stop()
+
+	var last *Operation
+	for {
+		op, err := h.api.GetOperation(ctx, name)
+		if err != nil {
+			if !transientError(err) {
+				return last, fmt.Errorf("could not poll operation %s: %w", name, err)
+			}
+			slog.WarnContext(ctx, "Could not poll operation", "operation", name, "error", err)
+		} else {
+			last = op
+			if onPoll != nil {
+				onPoll(op)
+			}
+			if op.Done {
+				// The cluster the operation changed is cached as it was before.
+				if strings.Contains(op.Metadata.Target, "/clusters/") {
+					h.forgetClusterState(op.Metadata.Target)
+				}
+				return op, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return last, fmt.Errorf("%w %s after %s", errOperationTimeout, name, timeout)
+			}
+			return last, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// operationTimeout returns the timeout_minutes argument of request, which must
+// be a whole number of minutes, at least 1.
+func operationTimeout(request mcp.CallToolRequest) (time.Duration, error) {
+	minutes := request.GetFloat("timeout_minutes", defaultOperationTimeout.Minutes())
+	if minutes < 1 || minutes != math.Trunc(minutes) {
+		return 0, fmt.Errorf("timeout_minutes must be a whole number of minutes, at least 1, got %v", minutes)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// transientError tells whether a poll that failed with err may succeed when
+// repeated. Missing resources, denied access and missing credentials do not
+// go away by waiting.
+func transientError(err error) bool {
+	var apiErr *genericCore.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	return !errors.Is(err, ErrNotFound) && !errors.Is(err, auth.ErrNoAccessToken)
+}
+
+// operationProgress returns an onPoll callback for waitForOperation that
+// reports every poll to the client as a notifications/progress message. It
+// returns nil if the client did not ask for progress.
+func operationProgress(ctx context.Context, request mcp.CallToolRequest) func(op *Operation) {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return nil
+	}
+	token := request.Params.Meta.ProgressToken
+	start := time.Now()
+	polls := 0
+	return func(op *Operation) {
+		polls++
+		state := "running"
+		if op.Done {
+			state = "done"
+		}
+		msg := fmt.Sprintf("%s %s: %s after %s", op.Metadata.Verb, ShortName(op.Metadata.Target), state,
+			time.Since(start).Round(time.Second))
+		if op.Metadata.StatusMessage != "" {
+			msg += " (" + op.Metadata.StatusMessage + ")"
+		}
+		if err := srv.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+			"progressToken": token,
+			"progress":      polls,
+			"message":       msg,
+		}); err != nil {
+			slog.WarnContext(ctx, "Could not send progress notification", "error", err)
+		}
+	}
+}
+
+// operationName resolves the operation tool argument, which may be a full
+// resource name or an operation ID within location.
+func operationName(projectID string, location string, operation string) (string, error) {
+	if strings.HasPrefix(operation, "projects/") {
+		return operation, nil
+	}
+	if location == "" {
+		return "", fmt.Errorf("location is required when operation %q is not a full resource name", operation)
+	}
+	return LocationName(projectID, location) + "/operations/" + operation, nil
+}
+
+func (h *handlers) listOperations(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	projectID := request.GetString("project_id", h.c.GetDefaultProjectID())
+	if projectID == "" {
+		return mcp.NewToolResultError("project_id argument not set"), nil
+	}
+	location := request.GetString("location", "")
+	state := request.GetString("state", "all")
+
+	var regions []string
+	if location != "" {
+		regions = []string{location}
+	} else {
+		found, _, err := h.regionsAndZones(ctx, projectID, false)
+		if err != nil {
+			return h.toolError(err, projectID), nil
+		}
+		regions = sortedRegions(found)
+	}
+
+	listed := make([][]Operation, len(regions))
+	errs := fanOut(ctx, len(regions), h.fanOut, func(ctx context.Context, i int) error {
+		var err error
+		listed[i], err = h.api.ListOperations(ctx, projectID, regions[i])
+		return err
+	})
+
+	var b strings.Builder
+	count := 0
+	var failed []FleetError
+	for r, ops := range listed {
+		if errs[r] != nil {
+			slog.WarnContext(ctx, "Could not list operations", "project", projectID, "region", regions[r], "error", errs[r])
+			failed = append(failed, h.fleetError(projectID, regions[r], errs[r]))
+			continue
+		}
+		for i := range ops {
+			if (state == "running" && ops[i].Done) || (state == "done" && !ops[i].Done) {
+				continue
+			}
+			b.WriteString(describeOperation(&ops[i]))
+			b.WriteString("\n")
+			count++
+		}
+	}
+	if count == 0 {
+		b.WriteString(fmt.Sprintf("No %s operations found in project %s.\n", state, projectID))
+	}
+	if len(failed) > 0 {
+		b.WriteString("\nThe operations of these regions could not be listed:\n")
+		for _, f := range failed {
+			fmt.Fprintf(&b, "  - %s: %s\n", f.Region, f.Error)
+			if f.Kind != "" {
+				fmt.Fprintf(&b, "    Kind: %s\n    Hint: %s\n", f.Kind, f.Hint)
+			}
+		}
+	}
+	return mcp.NewToolResultText(b.String()), nil
+}
+
+func (h *handlers) getOperation(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	projectID := request.GetString("project_id", h.c.GetDefaultProjectID())
+	operation, err := request.RequireString("operation")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	name, err := operationName(projectID, request.GetString("location", ""), operation)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	op, err := h.api.GetOperation(ctx, name)
+	if err != nil {
+		return h.toolError(fmt.Errorf("could not get operation %s: %w", name, err), projectID), nil
+	}
+	return mcp.NewToolResultText(describeOperation(op)), nil
+}
+
+func (h *handlers) waitOperation(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	projectID := request.GetString("project_id", h.c.GetDefaultProjectID())
+	operation, err := request.RequireString("operation")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	name, err := operationName(projectID, request.GetString("location", ""), operation)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	timeout, err := operationTimeout(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	op, err := h.waitForOperation(ctx, name, timeout, operationProgress(ctx, request))
+	if err != nil {
+		msg := fmt.Sprintf("Operation %s has not finished: %s\n", name, h.explainError(err, projectID))
+		if op != nil {
+			msg += describeOperation(op)
+		}
+		return mcp.NewToolResultError(msg), nil
+	}
+	return mcp.NewToolResultText(describeOperation(op)), nil
+}
+
+// describeOperation renders the state of op for tool output.
+func describeOperation(op *Operation) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Operation: %s\n", op.Name)
+	if op.Metadata.Verb != "" || op.Metadata.Target != "" {
+		fmt.Fprintf(&b, "Action: %s %s\n", op.Metadata.Verb, op.Metadata.Target)
+	}
+	if op.Metadata.CreateTime != "" {
+		fmt.Fprintf(&b, "Started: %s\n", op.Metadata.CreateTime)
+	}
+	switch {
+	case op.Error != nil:
+		fmt.Fprintf(&b, "Status: FAILED (code %d): %s\n", op.Error.Code, op.Error.Message)
+	case op.Done:
+		fmt.Fprintf(&b, "Status: DONE\n")
+	default:
+		fmt.Fprintf(&b, "Status: RUNNING\n")
+	}
+	if op.Metadata.EndTime != "" {
+		fmt.Fprintf(&b, "Finished: %s\n", op.Metadata.EndTime)
+	}
+	if op.Metadata.StatusMessage != "" {
+		fmt.Fprintf(&b, "Message: %s\n", op.Metadata.StatusMessage)
+	}
+	if op.Metadata.RequestedCancellation {
+		fmt.Fprintf(&b, "Cancellation requested\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,115 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/auth"
+	"github.com/nadig-google/cluster-director-mcp/pkg/config"
+	"github.com/nadig-google/cluster-director-mcp/pkg/transport"
+)
+
+// profileList is the output of use_profile without a profile.
+type profileList struct {
+	// InUse is the gcloud configuration the defaults come from.
+	InUse    string                       `json:"inUse"`
+	Profiles []config.GcloudConfiguration `json:"profiles"`
+}
+
+// profileSwitch is the output of use_profile with a profile.
+type profileSwitch struct {
+	config.GcloudConfiguration
+	// CredentialsSwitched tells whether Google Cloud is now called as the
+	// account of the configuration. When it is not, CredentialsReason tells
+	// why, and calls still go out as the previous account.
+	CredentialsSwitched bool   `json:"credentialsSwitched"`
+	CredentialsReason   string `json:"credentialsReason,omitempty"`
+}
+
+func (h *handlers) useProfile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name := request.GetString("profile", "")
+
+	if name == "" {
+		profiles, err := config.ListGcloudConfigurations()
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		inUse := h.c.GetGcloudConfiguration()
+		if inUse == "" {
+			inUse = config.ActiveGcloudConfiguration()
+		}
+		out, err := json.MarshalIndent(profileList{InUse: inUse, Profiles: profiles}, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	}
+
+	// The configuration is shared by every client of an HTTP transport, so
+	// one of them must not switch it for all.
+	if t := h.c.GetTransport(); t != "" && t != transport.Stdio {
+		return mcp.NewToolResultError(fmt.Sprintf("use_profile cannot switch profiles on the shared %s transport, as that would switch them for every client. "+
+			"Pass project_id to the tools instead, or start a server with --gcloud-configuration=%s.", t, name)), nil
+	}
+
+	profile, err := h.c.UseGcloudConfiguration(name)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	slog.InfoContext(ctx, "Switched gcloud configuration", "configuration", profile.Name, "project", profile.Project)
+
+	// Call Google Cloud as the account of the configuration, unless the
+	// credentials were given explicitly.
+	result := profileSwitch{GcloudConfiguration: *profile}
+	ts, switchable := h.tokens.(*auth.SwitchableTokenSource)
+	switch {
+	case h.c.GetCredentialsFile() != "":
+		result.CredentialsReason = fmt.Sprintf("credentials_file %s is used whatever the profile", h.c.GetCredentialsFile())
+	case !switchable:
+		result.CredentialsReason = "the server was started with fixed credentials"
+	case !auth.GcloudInstalled():
+		result.CredentialsReason = "gcloud is not installed, so the credentials of the configuration cannot be used"
+	default:
+		ts.Switch(auth.GcloudConfigurationTokenSource(profile.Name))
+		h.osLogin.Forget()
+		result.CredentialsSwitched = true
+		slog.InfoContext(ctx, "Switched credentials", "account", profile.Account)
+	}
+	if !result.CredentialsSwitched {
+		slog.WarnContext(ctx, "Kept the previous credentials", "configuration", profile.Name, "reason", result.CredentialsReason)
+	}
+
+	// What is known about clusters belongs to the previous project.
+	h.resetCaches()
+	if profile.Project != "" {
+		h.getAllRegionsAndZonesSupportedByHCS(ctx, profile.Project)
+	}
+	// Re-register the tools so that their project_id defaults follow.
+	if h.server != nil {
+		h.registerTools()
+	}
+
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return mcp.NewToolResultText(string(out)), nil
+}
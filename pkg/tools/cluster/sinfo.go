@@ -0,0 +1,316 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/remote"
+)
+
+// sinfoFormat prints one line per partition and node state as
+// partition|availability|state|node count|node list. The partition name is
+// suffixed with "*" for the default partition.
+const sinfoFormat = "%P|%a|%T|%D|%N"
+
+var sinfoCommand = remote.Command{Program: "sinfo", Args: []string{"--noheader", "--format=" + sinfoFormat}}
+
+// Node states reported by show_cluster_state. Any other Slurm state, e.g.
+// "completing" or "future", is reported as is.
+const (
+	NodeStateIdle  = "idle"
+	NodeStateAlloc = "alloc"
+	NodeStateMix   = "mix"
+	NodeStateDrain = "drain"
+	NodeStateDown  = "down"
+)
+
+// SinfoRecord is a group of nodes of one partition in the same state.
+type SinfoRecord struct {
+	Partition string `json:"partition"`
+	Default   bool   `json:"default,omitempty"`
+	// Available is the state of the partition, e.g. "up".
+	Available string `json:"available,omitempty"`
+	// State is one of the NodeState* constants or another Slurm state.
+	State string `json:"state"`
+	// SlurmState is the state as printed by Slurm, e.g. "drained*".
+	SlurmState string   `json:"slurmState,omitempty"`
+	Count      int      `json:"count"`
+	Nodes      []string `json:"nodes"`
+}
+
+// PartitionState is the number and names of the nodes of a partition in each
+// state.
+type PartitionState struct {
+	Name      string        `json:"name"`
+	Default   bool          `json:"default,omitempty"`
+	Available string        `json:"available,omitempty"`
+	Nodes     int           `json:"nodes"`
+	States    []SinfoRecord `json:"states"`
+}
+
+// ClusterState is the state of the nodes of a Slurm cluster by partition.
+type ClusterState struct {
+	Partitions []PartitionState `json:"partitions"`
+}
+
+// parseSinfo parses the output of sinfoCommand.
+func parseSinfo(output string) ([]SinfoRecord, error) {
+	var records []SinfoRecord
+	for i, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("line %d of sinfo output has %d fields, want 5: %q", i+1, len(fields), line)
+		}
+		count, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d of sinfo output has invalid node count %q", i+1, fields[3])
+		}
+		nodes, err := expandHostlist(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("line %d of sinfo output: %w", i+1, err)
+		}
+		partition := fields[0]
+		isDefault := strings.HasSuffix(partition, "*")
+		records = append(records, SinfoRecord{
+			Partition:  strings.TrimSuffix(partition, "*"),
+			Default:    isDefault,
+			Available:  fields[1],
+			State:      normalizeNodeState(fields[2]),
+			SlurmState: fields[2],
+			Count:      count,
+			Nodes:      nodes,
+		})
+	}
+	return records, nil
+}
+
+// normalizeNodeState maps a Slurm node state, in the long or short form and
+// with any of the flag suffixes Slurm appends, to a NodeState* constant.
+func normalizeNodeState(state string) string {
+	state = strings.ToLower(strings.TrimRight(state, "*~#!%$@^-+"))
+	switch state {
+	case "idle":
+		return NodeStateIdle
+	case "alloc", "allocated":
+		return NodeStateAlloc
+	case "mix", "mixed":
+		return NodeStateMix
+	case "drain", "drained", "draining", "drng":
+		return NodeStateDrain
+	case "down":
+		return NodeStateDown
+	}
+	return state
+}
+
+// expandHostlist expands a Slurm hostlist such as "a3-[001-003,7],login-001"
+// into the names of the hosts. Zero padding of ranges is preserved.
+func expandHostlist(hostlist string) ([]string, error) {
+	hostlist = strings.TrimSpace(hostlist)
+	if hostlist == "" || hostlist == "(null)" {
+		return nil, nil
+	}
+	var hosts []string
+	for _, item := range splitHostlist(hostlist) {
+		expanded, err := expandHostlistItem(item)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, expanded...)
+	}
+	return hosts, nil
+}
+
+// splitHostlist splits a hostlist on the commas outside brackets.
+func splitHostlist(hostlist string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i, r := range hostlist {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, hostlist[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(items, hostlist[start:])
+}
+
+// expandHostlistItem expands a single hostlist entry, which may hold several
+// bracketed ranges, e.g. "rack[1-2]-node[01-04]".
+func expandHostlistItem(item string) ([]string, error) {
+	open := strings.Index(item, "[")
+	if open < 0 {
+		return []string{item}, nil
+	}
+	closing := strings.Index(item[open:], "]")
+	if closing < 0 {
+		return nil, fmt.Errorf("unbalanced brackets in hostlist %q", item)
+	}
+	closing += open
+	prefix, ranges := item[:open], item[open+1:closing]
+
+	suffixes, err := expandHostlistItem(item[closing+1:])
+	if err != nil {
+		return nil, err
+	}
+	var hosts []string
+	for _, r := range strings.Split(ranges, ",") {
+		ids, err := expandHostRange(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range in hostlist %q: %w", item, err)
+		}
+		for _, id := range ids {
+			for _, suffix := range suffixes {
+				hosts = append(hosts, prefix+id+suffix)
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// expandHostRange expands "7" or "001-003" into the IDs it covers.
+func expandHostRange(r string) ([]string, error) {
+	lo, hi, isRange := strings.Cut(r, "-")
+	if !isRange {
+		if _, err := strconv.Atoi(lo); err != nil {
+			return nil, fmt.Errorf("%q is not a number", lo)
+		}
+		return []string{lo}, nil
+	}
+	start, err := strconv.Atoi(lo)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a number", lo)
+	}
+	end, err := strconv.Atoi(hi)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a number", hi)
+	}
+	if end < start {
+		return nil, fmt.Errorf("range %q ends before it starts", r)
+	}
+	ids := make([]string, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		ids = append(ids, fmt.Sprintf("%0*d", len(lo), i))
+	}
+	return ids, nil
+}
+
+// sinfoRecordsFromNodes groups the nodes reported by slurmrestd like sinfo
+// groups them.
+func sinfoRecordsFromNodes(nodes []SlurmNode) []SinfoRecord {
+	type key struct{ partition, state string }
+	groups := make(map[key]*SinfoRecord)
+	var order []key
+	for _, node := range nodes {
+		state := slurmNodeState(node.State)
+		partitions := node.Partitions
+		if len(partitions) == 0 {
+			partitions = []string{""}
+		}
+		for _, p := range partitions {
+			k := key{p, state}
+			r, ok := groups[k]
+			if !ok {
+				r = &SinfoRecord{Partition: p, State: state, SlurmState: strings.ToLower(strings.Join(node.State, "+"))}
+				groups[k] = r
+				order = append(order, k)
+			}
+			r.Count++
+			r.Nodes = append(r.Nodes, node.Name)
+		}
+	}
+	records := make([]SinfoRecord, 0, len(order))
+	for _, k := range order {
+		records = append(records, *groups[k])
+	}
+	return records
+}
+
+// slurmNodeState maps the state flags of a slurmrestd node to a NodeState*
+// constant. Drain and down flags take precedence over the base state.
+func slurmNodeState(flags []string) string {
+	if len(flags) == 0 {
+		return "unknown"
+	}
+	for _, f := range flags[1:] {
+		switch strings.ToUpper(f) {
+		case "DRAIN":
+			return NodeStateDrain
+		case "NOT_RESPONDING":
+			return NodeStateDown
+		}
+	}
+	return normalizeNodeState(flags[0])
+}
+
+// newClusterState groups records by partition. Partitions keep the order
+// Slurm reported them in, states are sorted by name.
+func newClusterState(records []SinfoRecord) ClusterState {
+	var state ClusterState
+	index := make(map[string]int)
+	for _, r := range records {
+		i, ok := index[r.Partition]
+		if !ok {
+			i = len(state.Partitions)
+			index[r.Partition] = i
+			state.Partitions = append(state.Partitions, PartitionState{Name: r.Partition, Available: r.Available})
+		}
+		p := &state.Partitions[i]
+		p.Default = p.Default || r.Default
+		p.Nodes += r.Count
+		p.States = append(p.States, r)
+	}
+	for i := range state.Partitions {
+		states := state.Partitions[i].States
+		sort.SliceStable(states, func(a, b int) bool { return states[a].State < states[b].State })
+	}
+	return state
+}
+
+// Summary renders s for people, one line per partition and state.
+func (s ClusterState) Summary() string {
+	if len(s.Partitions) == 0 {
+		return "The cluster has no Slurm nodes.\n"
+	}
+	var b strings.Builder
+	for _, p := range s.Partitions {
+		fmt.Fprintf(&b, "Partition %s", p.Name)
+		if p.Default {
+			b.WriteString(" (default)")
+		}
+		if p.Available != "" {
+			fmt.Fprintf(&b, " is %s", p.Available)
+		}
+		fmt.Fprintf(&b, ", %d node(s):\n", p.Nodes)
+		for _, r := range p.States {
+			fmt.Fprintf(&b, "  %-6s %4d  %s\n", r.State, r.Count, strings.Join(r.Nodes, ","))
+		}
+	}
+	return b.String()
+}
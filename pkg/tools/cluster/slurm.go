@@ -0,0 +1,579 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/auth"
+	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
+	"github.com/nadig-google/cluster-director-mcp/pkg/remote"
+)
+
+// Slurm backends. SlurmBackendAuto tries the REST API first and falls back to
+// SSH.
+const (
+	SlurmBackendAuto = "auto"
+	SlurmBackendREST = "rest"
+	SlurmBackendSSH  = "ssh"
+)
+
+// slurmRESTVersion is the slurmrestd API version queried through CallSlurm.
+const slurmRESTVersion = "v0.0.42"
+
+// SlurmRequest is a slurmrestd request forwarded by the Cluster Director
+// CallSlurm API.
+type SlurmRequest struct {
+	// User is the Slurm user the request is made as. The backends leave it
+	// empty, for the API to map the caller to their Slurm user.
+	User     string `json:"user,omitempty"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	BodyJSON string `json:"bodyJson"`
+}
+
+// SlurmResponse is the slurmrestd response returned by CallSlurm.
+type SlurmResponse struct {
+	StatusCode int    `json:"statusCode"`
+	BodyJSON   string `json:"bodyJson"`
+}
+
+// slurmNumber is a slurmrestd integer. Newer API versions wrap numbers in an
+// object that tells whether they are set or infinite.
+type slurmNumber struct {
+	Set      bool  `json:"set"`
+	Infinite bool  `json:"infinite"`
+	Number   int64 `json:"number"`
+}
+
+func (n *slurmNumber) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		type wrapped slurmNumber
+		return json.Unmarshal(data, (*wrapped)(n))
+	}
+	if bytes.Equal(data, []byte("null")) {
+		*n = slurmNumber{}
+		return nil
+	}
+	v, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return err
+	}
+	*n = slurmNumber{Set: true, Number: v}
+	return nil
+}
+
+func (n slurmNumber) MarshalJSON() ([]byte, error) {
+	switch {
+	case n.Infinite:
+		return []byte(`"infinite"`), nil
+	case !n.Set:
+		return []byte("null"), nil
+	}
+	return []byte(strconv.FormatInt(n.Number, 10)), nil
+}
+
+// SlurmNode is a node as reported by slurmrestd /nodes/.
+type SlurmNode struct {
+	Name        string      `json:"name"`
+	State       []string    `json:"state"`
+	Partitions  []string    `json:"partitions"`
+	CPUs        slurmNumber `json:"cpus"`
+	AllocCPUs   slurmNumber `json:"alloc_cpus"`
+	RealMemory  slurmNumber `json:"real_memory"`
+	Gres        string      `json:"gres"`
+	GresUsed    string      `json:"gres_used"`
+	Reason      string      `json:"reason"`
+	Address     string      `json:"address"`
+	Hostname    string      `json:"hostname"`
+	BootTime    slurmNumber `json:"boot_time"`
+	LastBusy    slurmNumber `json:"last_busy"`
+	ReasonSetBy string      `json:"reason_set_by_user"`
+}
+
+// SlurmJob is a job as reported by slurmrestd /jobs/.
+type SlurmJob struct {
+	JobID       slurmNumber `json:"job_id"`
+	Name        string      `json:"name"`
+	UserName    string      `json:"user_name"`
+	Account     string      `json:"account"`
+	Partition   string      `json:"partition"`
+	JobState    []string    `json:"job_state"`
+	StateReason string      `json:"state_reason"`
+	Nodes       string      `json:"nodes"`
+	NodeCount   slurmNumber `json:"node_count"`
+	TimeLimit   slurmNumber `json:"time_limit"`
+	SubmitTime  slurmNumber `json:"submit_time"`
+	StartTime   slurmNumber `json:"start_time"`
+	EndTime     slurmNumber `json:"end_time"`
+	TresPerNode string      `json:"tres_per_node"`
+}
+
+// SlurmPartition is a partition as reported by slurmrestd /partitions/.
+type SlurmPartition struct {
+	Name  string `json:"name"`
+	Nodes struct {
+		Configured string `json:"configured"`
+		Total      int64  `json:"total"`
+	} `json:"nodes"`
+	Partition struct {
+		State []string `json:"state"`
+	} `json:"partition"`
+	Maximums struct {
+		Time  slurmNumber `json:"time"`
+		Nodes slurmNumber `json:"nodes"`
+	} `json:"maximums"`
+	Defaults struct {
+		Time slurmNumber `json:"time"`
+	} `json:"defaults"`
+}
+
+// SlurmReservation is a reservation as reported by slurmrestd /reservations/.
+type SlurmReservation struct {
+	Name      string      `json:"name"`
+	NodeList  string      `json:"node_list"`
+	NodeCount int64       `json:"node_count"`
+	Partition string      `json:"partition"`
+	Users     string      `json:"users"`
+	Accounts  string      `json:"accounts"`
+	StartTime slurmNumber `json:"start_time"`
+	EndTime   slurmNumber `json:"end_time"`
+	Flags     []string    `json:"flags"`
+}
+
+// slurmBackend reads the state of the Slurm controller of one cluster. Every
+// implementation returns the slurmrestd data model, whatever its transport.
+type slurmBackend interface {
+	// Name is one of the SlurmBackend* constants.
+	Name() string
+	Nodes(ctx context.Context) ([]SlurmNode, error)
+	// NodeStates returns the nodes grouped by partition and state, like
+	// sinfo prints them.
+	NodeStates(ctx context.Context) ([]SinfoRecord, error)
+	Jobs(ctx context.Context) ([]JobRecord, error)
+	Partitions(ctx context.Context) ([]SlurmPartition, error)
+	Reservations(ctx context.Context) ([]SlurmReservation, error)
+	// Submit submits a batch job and returns its ID.
+	Submit(ctx context.Context, sub JobSubmission) (string, error)
+	// CurrentUser returns the Slurm user requests are made as.
+	CurrentUser(ctx context.Context) (string, error)
+	// ControlJob takes one of the JobAction* actions on a job.
+	ControlJob(ctx context.Context, action string, jobID string) error
+	// History returns the jobs recorded by Slurm accounting.
+	History(ctx context.Context, q HistoryQuery) ([]AccountingRecord, error)
+}
+
+// decodeSlurm unmarshals a slurmrestd response into out, surfacing the errors
+// slurmrestd reports in the body.
+func decodeSlurm(body []byte, out interface{}) error {
+	var errs struct {
+		Errors []struct {
+			Error       string `json:"error"`
+			Description string `json:"description"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &errs); err == nil && len(errs.Errors) > 0 {
+		e := errs.Errors[0]
+		return fmt.Errorf("slurm error: %s %s", e.Error, e.Description)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("could not parse Slurm response: %w", err)
+	}
+	return nil
+}
+
+// restSlurmBackend queries slurmrestd through the Cluster Director CallSlurm
+// API, without needing network access to the cluster.
+type restSlurmBackend struct {
+	api     ClusterDirectorClient
+	cluster string
+}
+
+func (b *restSlurmBackend) Name() string { return SlurmBackendREST }
+
+func (b *restSlurmBackend) get(ctx context.Context, resource string, out interface{}) error {
+	return b.call(ctx, http.MethodGet, resource+"/", nil, out)
+}
+
+// call sends in, if not nil, as JSON to the slurmrestd endpoint resource and
+// decodes the response into out.
+func (b *restSlurmBackend) call(ctx context.Context, method string, resource string, in interface{}, out interface{}) error {
+	path := "/slurm/" + slurmRESTVersion + "/" + resource
+	req := &SlurmRequest{
+		Method: method,
+		Path:   path,
+	}
+	if in != nil {
+		body, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		req.BodyJSON = string(body)
+	}
+	resp, err := b.api.CallSlurm(ctx, b.cluster, req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 0 && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slurmrestd returned HTTP %d for %s: %s", resp.StatusCode, path, resp.BodyJSON)
+	}
+	return decodeSlurm([]byte(resp.BodyJSON), out)
+}
+
+func (b *restSlurmBackend) Nodes(ctx context.Context) ([]SlurmNode, error) {
+	var resp struct {
+		Nodes []SlurmNode `json:"nodes"`
+	}
+	err := b.get(ctx, "nodes", &resp)
+	return resp.Nodes, err
+}
+
+func (b *restSlurmBackend) NodeStates(ctx context.Context) ([]SinfoRecord, error) {
+	nodes, err := b.Nodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sinfoRecordsFromNodes(nodes), nil
+}
+
+func (b *restSlurmBackend) Jobs(ctx context.Context) ([]JobRecord, error) {
+	var resp struct {
+		Jobs []SlurmJob `json:"jobs"`
+	}
+	if err := b.get(ctx, "jobs", &resp); err != nil {
+		return nil, err
+	}
+	return jobRecordsFromSlurmJobs(resp.Jobs, time.Now()), nil
+}
+
+func (b *restSlurmBackend) Partitions(ctx context.Context) ([]SlurmPartition, error) {
+	var resp struct {
+		Partitions []SlurmPartition `json:"partitions"`
+	}
+	err := b.get(ctx, "partitions", &resp)
+	return resp.Partitions, err
+}
+
+func (b *restSlurmBackend) Reservations(ctx context.Context) ([]SlurmReservation, error) {
+	var resp struct {
+		Reservations []SlurmReservation `json:"reservations"`
+	}
+	err := b.get(ctx, "reservations", &resp)
+	return resp.Reservations, err
+}
+
+// loginNode is a VM the Slurm CLI can be run on.
+type loginNode struct {
+	Name    string
+	Project string
+	Zone    string
+}
+
+// loginNodes returns the login nodes of cluster. Instances are resource names
+// such as projects/p/zones/z/instances/name.
+func loginNodes(cluster *Cluster) []loginNode {
+	var nodes []loginNode
+	login := cluster.Orchestrator.Slurm.LoginNodes
+	for _, inst := range login.Instances {
+		parts := strings.Split(inst.Instance, "/")
+		if len(parts) == 6 && parts[0] == "projects" && parts[2] == "zones" && parts[4] == "instances" {
+			nodes = append(nodes, loginNode{Name: parts[5], Project: parts[1], Zone: parts[3]})
+			continue
+		}
+		// A bare instance name lives in the project of the cluster
+		project := ""
+		if p := strings.Split(cluster.Name, "/"); len(p) > 1 && p[0] == "projects" {
+			project = p[1]
+		}
+		if inst.Instance != "" && project != "" && login.Zone != "" {
+			nodes = append(nodes, loginNode{Name: ShortName(inst.Instance), Project: project, Zone: login.Zone})
+		}
+	}
+	return nodes
+}
+
+// sshSlurmBackend runs the Slurm CLI on a login node, failing over to the
+// next login node when one cannot be reached.
+type sshSlurmBackend struct {
+	// run runs a command of slurmCommands on a login node.
+	run   func(ctx context.Context, node remote.Node, cmd remote.Command) (string, error)
+	nodes []loginNode
+	// reached, if not nil, is called with the login node that ran a command.
+	reached func(node loginNode)
+}
+
+func (b *sshSlurmBackend) Name() string { return SlurmBackendSSH }
+
+// exec runs cmd on the first login node that can be reached. Only failures to
+// reach a node move on to the next one: once cmd may have run it is never run
+// again.
+func (b *sshSlurmBackend) exec(ctx context.Context, cmd remote.Command) (string, error) {
+	var tried []string
+	var errs []error
+	for _, node := range b.nodes {
+		output, err := b.run(ctx, remote.Node{Project: node.Project, Zone: node.Zone, Instance: node.Name}, cmd)
+		if err == nil {
+			if b.reached != nil {
+				b.reached(node)
+			}
+			return output, nil
+		}
+		var connectErr *remote.ConnectError
+		if !errors.As(err, &connectErr) {
+			return "", fmt.Errorf("login node %s: %w", node.Name, err)
+		}
+		slog.WarnContext(ctx, "Could not run command on login node", "command", cmd.String(), "node", node.Name, "error", err)
+		tried = append(tried, node.Name)
+		errs = append(errs, err)
+	}
+	if len(tried) == 0 {
+		return "", fmt.Errorf("the cluster has no login node")
+	}
+	return "", fmt.Errorf("could not reach login node(s) %s to run %s: %w", strings.Join(tried, ", "), cmd.Program, errors.Join(errs...))
+}
+
+func (b *sshSlurmBackend) get(ctx context.Context, cmd remote.Command, out interface{}) error {
+	output, err := b.exec(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	return decodeSlurm([]byte(output), out)
+}
+
+func (b *sshSlurmBackend) Nodes(ctx context.Context) ([]SlurmNode, error) {
+	var resp struct {
+		Nodes []SlurmNode `json:"nodes"`
+	}
+	err := b.get(ctx, remote.Command{Program: "scontrol", Args: []string{"show", "nodes", "--json"}}, &resp)
+	return resp.Nodes, err
+}
+
+func (b *sshSlurmBackend) NodeStates(ctx context.Context) ([]SinfoRecord, error) {
+	output, err := b.exec(ctx, sinfoCommand)
+	if err != nil {
+		return nil, err
+	}
+	return parseSinfo(output)
+}
+
+func (b *sshSlurmBackend) Jobs(ctx context.Context) ([]JobRecord, error) {
+	output, err := b.exec(ctx, squeueCommand)
+	if err != nil {
+		return nil, err
+	}
+	return parseSqueue(output)
+}
+
+func (b *sshSlurmBackend) Partitions(ctx context.Context) ([]SlurmPartition, error) {
+	var resp struct {
+		Partitions []SlurmPartition `json:"partitions"`
+	}
+	err := b.get(ctx, remote.Command{Program: "scontrol", Args: []string{"show", "partitions", "--json"}}, &resp)
+	return resp.Partitions, err
+}
+
+func (b *sshSlurmBackend) Reservations(ctx context.Context) ([]SlurmReservation, error) {
+	var resp struct {
+		Reservations []SlurmReservation `json:"reservations"`
+	}
+	err := b.get(ctx, remote.Command{Program: "scontrol", Args: []string{"show", "reservations", "--json"}}, &resp)
+	return resp.Reservations, err
+}
+
+// slurmChangeError marks an error returned after a request that changes the
+// cluster may have reached Slurm. Such requests are not retried with another
+// backend so that they never run twice.
+type slurmChangeError struct {
+	err error
+}
+
+func (e *slurmChangeError) Error() string { return e.err.Error() }
+
+func (e *slurmChangeError) Unwrap() error { return e.err }
+
+// slurmChange returns err, the error of a request that changes the cluster,
+// as a slurmChangeError unless the request surely never reached Slurm: the
+// CallSlurm API rejected it, or no login node could be reached. A CallSlurm
+// request failing with another 5xx status may have been passed on already.
+func slurmChange(err error) error {
+	var apiErr *genericCore.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode < http.StatusInternalServerError || apiErr.StatusCode == http.StatusServiceUnavailable {
+			return err
+		}
+		return &slurmChangeError{err}
+	}
+	var connectErr *remote.ConnectError
+	if errors.Is(err, ErrNotFound) || errors.Is(err, auth.ErrNoAccessToken) || errors.As(err, &connectErr) {
+		return err
+	}
+	return &slurmChangeError{err}
+}
+
+// slurmQuery runs query against the Slurm backend selected for the cluster
+// whose resource name is clusterName. With SlurmBackendAuto the REST backend
+// is tried first, falling back to ssh. The backend that worked last is
+// remembered for the cluster and tried first. There is no fallback after a
+// slurmChangeError.
+func slurmQuery[T any](ctx context.Context, h *handlers, clusterName string, backends map[string]slurmBackend, selected string, query func(slurmBackend) (T, error)) (T, string, error) {
+	order := []string{selected}
+	if selected == SlurmBackendAuto {
+		order = []string{SlurmBackendREST, SlurmBackendSSH}
+		if last, _, _ := h.slurmBackendFor.get(clusterName); last == SlurmBackendSSH {
+			order = []string{SlurmBackendSSH, SlurmBackendREST}
+		}
+	}
+
+	var zero T
+	var lastErr error
+	for _, name := range order {
+		backend, ok := backends[name]
+		if !ok {
+			if selected == SlurmBackendAuto {
+				continue
+			}
+			return zero, "", fmt.Errorf("Slurm backend %q is not available", name)
+		}
+		result, err := query(backend)
+		if err == nil {
+			if selected == SlurmBackendAuto {
+				h.slurmBackendFor.set(clusterName, name)
+			}
+			return result, name, nil
+		}
+		slog.WarnContext(ctx, "Slurm backend failed", "backend", name, "cluster", clusterName, "error", err)
+		lastErr = err
+		var changeErr *slurmChangeError
+		if errors.As(err, &changeErr) {
+			break
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no Slurm backend is available for cluster %s", clusterName)
+	}
+	return zero, "", lastErr
+}
+
+// slurmBackends returns the Slurm backends that can reach the cluster called
+// clusterName, and the resource name of the cluster. The ssh backend tries
+// the login node that answered last first.
+func (h *handlers) slurmBackends(ctx context.Context, projectID string, clusterName string) (map[string]slurmBackend, string, error) {
+	cluster, _, err := h.findCluster(ctx, projectID, clusterName, false)
+	if err != nil {
+		return nil, "", err
+	}
+	backends := map[string]slurmBackend{
+		SlurmBackendREST: &restSlurmBackend{api: h.api, cluster: cluster.Name},
+	}
+	nodes := loginNodes(cluster)
+	if len(nodes) == 0 {
+		return backends, cluster.Name, nil
+	}
+	// Clusters of the same name in other projects or regions are others.
+	last, _, _ := h.loginNodeFor.get(cluster.Name)
+	for i, node := range nodes {
+		if node.Name == last {
+			nodes[0], nodes[i] = nodes[i], nodes[0]
+		}
+	}
+	backends[SlurmBackendSSH] = &sshSlurmBackend{
+		run:   h.runRemote,
+		nodes: nodes,
+		reached: func(node loginNode) {
+			h.loginNodeFor.set(cluster.Name, node.Name)
+		},
+	}
+	return backends, cluster.Name, nil
+}
+
+// slurmTarget is the cluster and backend a Slurm tool acts on.
+type slurmTarget struct {
+	projectID   string
+	clusterName string
+	backend     string
+}
+
+// slurmTargetFromRequest resolves the cluster and backend arguments shared by
+// the Slurm tools.
+func (h *handlers) slurmTargetFromRequest(request mcp.CallToolRequest) (*slurmTarget, error) {
+	t := &slurmTarget{
+		projectID: request.GetString("project_id", h.c.GetDefaultProjectID()),
+		backend:   request.GetString("slurm_backend", h.c.GetSlurmBackend()),
+	}
+	if t.projectID == "" {
+		return nil, fmt.Errorf("project_id argument not set")
+	}
+	clusterName, err := request.RequireString("clusterName")
+	if err != nil {
+		return nil, err
+	}
+	t.clusterName = clusterName
+
+	switch t.backend {
+	case SlurmBackendAuto, SlurmBackendREST, SlurmBackendSSH:
+	default:
+		return nil, fmt.Errorf("unknown slurm_backend %q, use auto, rest or ssh", t.backend)
+	}
+	return t, nil
+}
+
+// querySlurm runs the read-only query on the cluster selected by request.
+func (h *handlers) querySlurm(ctx context.Context, request mcp.CallToolRequest, key string, query func(slurmBackend) (interface{}, error)) (*mcp.CallToolResult, error) {
+	t, err := h.slurmTargetFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return h.runSlurm(ctx, t, key, query), nil
+}
+
+// runSlurm runs query on the Slurm backend of t and returns its result as JSON
+// under key. Results with a Summary method are preceded by their summary.
+func (h *handlers) runSlurm(ctx context.Context, t *slurmTarget, key string, query func(slurmBackend) (interface{}, error)) *mcp.CallToolResult {
+	backends, resourceName, err := h.slurmBackends(ctx, t.projectID, t.clusterName)
+	if err != nil {
+		return h.toolError(err, t.projectID)
+	}
+	if t.backend == SlurmBackendSSH && backends[SlurmBackendSSH] == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("cluster %s has no login node to reach over ssh", t.clusterName))
+	}
+
+	result, backend, err := slurmQuery(ctx, h, resourceName, backends, t.backend, query)
+	if err != nil {
+		return h.toolError(fmt.Errorf("could not query Slurm on cluster %s: %w", t.clusterName, err), t.projectID)
+	}
+	out, err := json.MarshalIndent(map[string]interface{}{
+		"backend": backend,
+		key:       result,
+	}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+	if s, ok := result.(interface{ Summary() string }); ok {
+		return mcp.NewToolResultText(s.Summary() + "\n" + string(out))
+	}
+	return mcp.NewToolResultText(string(out))
+}
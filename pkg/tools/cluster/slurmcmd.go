@@ -0,0 +1,89 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"regexp"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/remote"
+)
+
+var countPattern = regexp.MustCompile(`^[0-9]{1,6}$`)
+
+// slurmCommands are the only commands the ssh Slurm backend runs on login
+// nodes. Every option that takes a value is checked against the same pattern
+// the tools validate their arguments with.
+var slurmCommands = remote.Allowlist{
+	"id": {
+		Path:  "/usr/bin/id",
+		Flags: map[string]remote.Validator{"-un": nil},
+	},
+	"sinfo": {
+		Path: "/usr/local/bin/sinfo",
+		Flags: map[string]remote.Validator{
+			"--noheader": nil,
+			"--format":   remote.OneOf(sinfoFormat),
+		},
+	},
+	"squeue": {
+		Path: "/usr/local/bin/squeue",
+		Flags: map[string]remote.Validator{
+			"--noheader": nil,
+			"--format":   remote.OneOf(squeueFormat),
+		},
+	},
+	"sacct": {
+		Path: "/usr/local/bin/sacct",
+		Flags: map[string]remote.Validator{
+			"--noheader":  nil,
+			"--parsable2": nil,
+			"--allusers":  nil,
+			"--format":    remote.OneOf(sacctFormat),
+			"--starttime": remote.Matches(sacctTimePattern),
+			"--endtime":   remote.Matches(sacctTimePattern),
+			"--user":      remote.Matches(slurmNamePattern),
+			"--partition": remote.Matches(slurmNamePattern),
+			"--state":     remote.Matches(slurmNamePattern),
+		},
+	},
+	"scontrol": {
+		Path: "/usr/local/bin/scontrol",
+		Subcommands: []string{
+			"show nodes", "show partitions", "show reservations",
+			"hold", "release", "requeue",
+		},
+		Flags:    map[string]remote.Validator{"--json": nil},
+		Operands: remote.Matches(jobIDPattern),
+	},
+	"scancel": {
+		Path:     "/usr/local/bin/scancel",
+		Operands: remote.Matches(jobIDPattern),
+	},
+	"sbatch": {
+		Path: "/usr/local/bin/sbatch",
+		Flags: map[string]remote.Validator{
+			"--parsable":      nil,
+			"--partition":     remote.Matches(slurmNamePattern),
+			"--nodes":         remote.Matches(countPattern),
+			"--gpus-per-node": remote.Matches(countPattern),
+			"--time":          remote.Matches(timeLimitPattern),
+			"--job-name":      remote.Matches(jobNamePattern),
+			"--chdir":         remote.Matches(scriptPathPattern),
+		},
+		Operands: remote.Matches(scriptPathPattern),
+		// The script is read from stdin when no path is given.
+		Stdin: true,
+	},
+}
@@ -0,0 +1,297 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/remote"
+)
+
+// squeueFormat prints one line per job as
+// id|user|partition|state|reason|elapsed|node count|node list|gres|name. The
+// name comes last as it is the only field that may contain the separator.
+const squeueFormat = "%i|%u|%P|%T|%r|%M|%D|%N|%b|%j"
+
+var squeueCommand = remote.Command{Program: "squeue", Args: []string{"--noheader", "--format=" + squeueFormat}}
+
+// squeueFields is the number of fields printed by squeueCommand.
+const squeueFields = 10
+
+// defaultMaxJobs is how many jobs show_job_state lists by default.
+const defaultMaxJobs = 100
+
+// jobStateCodes maps the compact job states of Slurm to the long ones.
+var jobStateCodes = map[string]string{
+	"BF":  "BOOT_FAIL",
+	"CA":  "CANCELLED",
+	"CD":  "COMPLETED",
+	"CF":  "CONFIGURING",
+	"CG":  "COMPLETING",
+	"DL":  "DEADLINE",
+	"F":   "FAILED",
+	"NF":  "NODE_FAIL",
+	"OOM": "OUT_OF_MEMORY",
+	"PD":  "PENDING",
+	"PR":  "PREEMPTED",
+	"R":   "RUNNING",
+	"RQ":  "REQUEUED",
+	"RS":  "RESIZING",
+	"S":   "SUSPENDED",
+	"TO":  "TIMEOUT",
+}
+
+// JobRecord is a Slurm job as listed by show_job_state.
+type JobRecord struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	User      string `json:"user"`
+	Partition string `json:"partition"`
+	// State is the long Slurm job state, e.g. "PENDING".
+	State string `json:"state"`
+	// Reason is why the job is pending or was stopped, e.g. "Resources".
+	Reason    string `json:"reason,omitempty"`
+	Elapsed   string `json:"elapsed,omitempty"`
+	NodeCount int    `json:"nodeCount"`
+	Nodes     string `json:"nodes,omitempty"`
+	GRES      string `json:"gres,omitempty"`
+}
+
+// JobFilter selects jobs. Empty fields match every job.
+type JobFilter struct {
+	User      string
+	Partition string
+	// State is a long or compact job state, e.g. "PENDING" or "PD".
+	State string
+	// Name matches the jobs whose name contains it.
+	Name string
+}
+
+// normalizeJobState returns the long form of a Slurm job state.
+func normalizeJobState(state string) string {
+	state = strings.ToUpper(strings.TrimSpace(state))
+	if long, ok := jobStateCodes[state]; ok {
+		return long
+	}
+	return state
+}
+
+// Match reports whether job is selected by f.
+func (f JobFilter) Match(job JobRecord) bool {
+	return (f.User == "" || job.User == f.User) &&
+		(f.Partition == "" || job.Partition == f.Partition) &&
+		(f.State == "" || job.State == normalizeJobState(f.State)) &&
+		(f.Name == "" || strings.Contains(job.Name, f.Name))
+}
+
+// parseSqueue parses the output of squeueCommand.
+func parseSqueue(output string) ([]JobRecord, error) {
+	var jobs []JobRecord
+	for i, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "|", squeueFields)
+		if len(fields) != squeueFields {
+			return nil, fmt.Errorf("line %d of squeue output has %d fields, want %d: %q", i+1, len(fields), squeueFields, line)
+		}
+		for j := range fields[:squeueFields-1] {
+			fields[j] = strings.TrimSpace(fields[j])
+		}
+		count, err := strconv.Atoi(fields[6])
+		if err != nil {
+			return nil, fmt.Errorf("line %d of squeue output has invalid node count %q", i+1, fields[6])
+		}
+		jobs = append(jobs, JobRecord{
+			ID:        fields[0],
+			User:      fields[1],
+			Partition: fields[2],
+			State:     normalizeJobState(fields[3]),
+			Reason:    slurmOptional(fields[4]),
+			Elapsed:   fields[5],
+			NodeCount: count,
+			Nodes:     slurmOptional(fields[7]),
+			GRES:      slurmOptional(fields[8]),
+			Name:      fields[9],
+		})
+	}
+	return jobs, nil
+}
+
+// slurmOptional returns "" for the placeholders Slurm prints for unset
+// fields.
+func slurmOptional(s string) string {
+	switch s {
+	case "N/A", "(null)", "None", "n/a":
+		return ""
+	}
+	return s
+}
+
+// jobRecordsFromSlurmJobs converts jobs reported by slurmrestd as if they had
+// been listed by squeueCommand at now.
+func jobRecordsFromSlurmJobs(jobs []SlurmJob, now time.Time) []JobRecord {
+	records := make([]JobRecord, 0, len(jobs))
+	for _, job := range jobs {
+		state := "UNKNOWN"
+		if len(job.JobState) > 0 {
+			state = normalizeJobState(job.JobState[0])
+		}
+		r := JobRecord{
+			ID:        strconv.FormatInt(job.JobID.Number, 10),
+			Name:      job.Name,
+			User:      job.UserName,
+			Partition: job.Partition,
+			State:     state,
+			Reason:    slurmOptional(job.StateReason),
+			NodeCount: int(job.NodeCount.Number),
+			Nodes:     slurmOptional(job.Nodes),
+			GRES:      slurmOptional(job.TresPerNode),
+		}
+		if job.StartTime.Set && job.StartTime.Number > 0 {
+			start := time.Unix(job.StartTime.Number, 0)
+			end := now
+			if job.EndTime.Set && job.EndTime.Number > 0 && state != "RUNNING" {
+				end = time.Unix(job.EndTime.Number, 0)
+			}
+			if end.After(start) {
+				r.Elapsed = formatSlurmDuration(end.Sub(start))
+			}
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// formatSlurmDuration formats d like Slurm does: [days-]hours:minutes:seconds,
+// leaving out zero days and hours.
+func formatSlurmDuration(d time.Duration) string {
+	secs := int64(d / time.Second)
+	days, secs := secs/86400, secs%86400
+	hours, secs := secs/3600, secs%3600
+	mins, secs := secs/60, secs%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%d-%02d:%02d:%02d", days, hours, mins, secs)
+	case hours > 0:
+		return fmt.Sprintf("%d:%02d:%02d", hours, mins, secs)
+	}
+	return fmt.Sprintf("%d:%02d", mins, secs)
+}
+
+// JobState is the result of show_job_state: the jobs selected by a filter,
+// summarised by state, user and pending reason.
+type JobState struct {
+	// Total is the number of jobs selected, Jobs lists at most the first
+	// max_results of them.
+	Total          int                       `json:"total"`
+	ByState        map[string]int            `json:"byState"`
+	ByUser         map[string]map[string]int `json:"byUser"`
+	PendingReasons map[string]int            `json:"pendingReasons,omitempty"`
+	Jobs           []JobRecord               `json:"jobs"`
+}
+
+// newJobState selects the jobs matching filter and lists at most limit of
+// them.
+func newJobState(jobs []JobRecord, filter JobFilter, limit int) JobState {
+	state := JobState{
+		ByState:        make(map[string]int),
+		ByUser:         make(map[string]map[string]int),
+		PendingReasons: make(map[string]int),
+		Jobs:           []JobRecord{},
+	}
+	for _, job := range jobs {
+		if !filter.Match(job) {
+			continue
+		}
+		state.Total++
+		state.ByState[job.State]++
+		if state.ByUser[job.User] == nil {
+			state.ByUser[job.User] = make(map[string]int)
+		}
+		state.ByUser[job.User][job.State]++
+		if job.State == "PENDING" && job.Reason != "" {
+			state.PendingReasons[job.Reason]++
+		}
+		if limit <= 0 || len(state.Jobs) < limit {
+			state.Jobs = append(state.Jobs, job)
+		}
+	}
+	return state
+}
+
+// Summary renders s for people.
+func (s JobState) Summary() string {
+	if s.Total == 0 {
+		return "No matching jobs.\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d job(s): %s\n", s.Total, formatCounts(s.ByState))
+
+	users := make([]string, 0, len(s.ByUser))
+	for user := range s.ByUser {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		fmt.Fprintf(&b, "  %s: %s\n", user, formatCounts(s.ByUser[user]))
+	}
+	if len(s.PendingReasons) > 0 {
+		fmt.Fprintf(&b, "Pending because of: %s\n", formatCounts(s.PendingReasons))
+	}
+	if len(s.Jobs) < s.Total {
+		fmt.Fprintf(&b, "Listing the first %d job(s).\n", len(s.Jobs))
+	}
+	for _, job := range s.Jobs {
+		fmt.Fprintf(&b, "%s %s (%s) %s on %s", job.ID, job.Name, job.User, job.State, job.Partition)
+		if job.Reason != "" {
+			fmt.Fprintf(&b, ", reason %s", job.Reason)
+		}
+		if job.Elapsed != "" {
+			fmt.Fprintf(&b, ", elapsed %s", job.Elapsed)
+		}
+		if job.Nodes != "" {
+			fmt.Fprintf(&b, ", %d node(s) %s", job.NodeCount, job.Nodes)
+		}
+		if job.GRES != "" {
+			fmt.Fprintf(&b, ", gres %s", job.GRES)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// formatCounts renders counts as "KEY n, ..." with the largest count first.
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s %d", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,335 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/remote"
+)
+
+// maxJobScriptSize bounds the scripts sent by submit_job, which are held in
+// memory and stored by slurmctld.
+const maxJobScriptSize = 64 * 1024
+
+var (
+	jobNamePattern    = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+	scriptPathPattern = regexp.MustCompile(`^/[A-Za-z0-9._/-]+$`)
+	timeLimitPattern  = regexp.MustCompile(`^(?:(\d+)-)?(\d+)(?::(\d+))?(?::(\d+))?$`)
+)
+
+// JobSubmission is a batch job to submit with sbatch.
+type JobSubmission struct {
+	// Script is the body of the batch script. Exactly one of Script and
+	// ScriptPath is set.
+	Script string
+	// ScriptPath is the path of a batch script on the shared storage of the
+	// cluster.
+	ScriptPath  string
+	Partition   string
+	Nodes       int
+	GPUsPerNode int
+	// TimeLimit is in one of the formats accepted by sbatch --time.
+	TimeLimit        string
+	JobName          string
+	WorkingDirectory string
+}
+
+// SubmittedJob is the result of submit_job.
+type SubmittedJob struct {
+	JobID     string `json:"jobId"`
+	Cluster   string `json:"cluster"`
+	Partition string `json:"partition"`
+	Nodes     int    `json:"nodes"`
+}
+
+// Summary renders j for people.
+func (j SubmittedJob) Summary() string {
+	return fmt.Sprintf("Submitted job %s to partition %s of cluster %s on %d node(s). Use show_job_state to follow it.\n",
+		j.JobID, j.Partition, j.Cluster, j.Nodes)
+}
+
+// parseTimeLimit returns the number of minutes of a time limit in one of the
+// formats of sbatch --time: minutes, minutes:seconds, hours:minutes:seconds,
+// days-hours, days-hours:minutes or days-hours:minutes:seconds.
+func parseTimeLimit(limit string) (int64, error) {
+	m := timeLimitPattern.FindStringSubmatch(limit)
+	if m == nil {
+		return 0, fmt.Errorf("time_limit %q is not in a format accepted by sbatch, e.g. 90, 1:30:00 or 2-00:00:00", limit)
+	}
+	var n [4]int64
+	for i, s := range m[1:] {
+		if s != "" {
+			n[i], _ = strconv.ParseInt(s, 10, 64)
+		}
+	}
+	days, first, second, third := n[0], n[1], n[2], n[3]
+	var secs int64
+	switch {
+	case m[1] != "":
+		// days-hours[:minutes[:seconds]]
+		secs = days*86400 + first*3600 + second*60 + third
+	case m[4] != "":
+		// hours:minutes:seconds
+		secs = first*3600 + second*60 + third
+	default:
+		// minutes[:seconds]
+		secs = first*60 + second
+	}
+	minutes := (secs + 59) / 60
+	if minutes == 0 {
+		return 0, fmt.Errorf("time_limit %q must be at least one second", limit)
+	}
+	return minutes, nil
+}
+
+// partitionCapacity returns the number of static nodes of the partition with
+// ID partitionID, which is 0 when it only has nodes started on demand, and the
+// number of GPUs of its smallest node.
+func partitionCapacity(cluster *Cluster, partitionID string) (nodes int64, gpusPerNode int64, found bool) {
+	var partition *Partition
+	for i := range cluster.Orchestrator.Slurm.Partitions {
+		if cluster.Orchestrator.Slurm.Partitions[i].ID == partitionID {
+			partition = &cluster.Orchestrator.Slurm.Partitions[i]
+		}
+	}
+	if partition == nil {
+		return 0, 0, false
+	}
+	gpusPerNode = -1
+	for _, id := range partition.NodeSetIDs {
+		for _, ns := range cluster.Orchestrator.Slurm.NodeSets {
+			if ns.ID != id {
+				continue
+			}
+			count, _ := parseCount(ns.StaticNodeCount)
+			nodes += count
+			perNode := sizeOfNodeSet(cluster, ns, 1).Accelerators
+			if gpusPerNode < 0 || perNode < gpusPerNode {
+				gpusPerNode = perNode
+			}
+		}
+	}
+	if gpusPerNode < 0 {
+		gpusPerNode = 0
+	}
+	return nodes, gpusPerNode, true
+}
+
+// validateSubmission checks that sub can run on cluster, filling in the
+// default partition. It returns one message per problem found.
+func validateSubmission(cluster *Cluster, sub *JobSubmission) []string {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch {
+	case sub.Script == "" && sub.ScriptPath == "":
+		addf("either script or script_path is required")
+	case sub.Script != "" && sub.ScriptPath != "":
+		addf("only one of script and script_path may be set")
+	case sub.Script != "":
+		if !strings.HasPrefix(sub.Script, "#!") {
+			addf("script must start with an interpreter line such as #!/bin/bash")
+		}
+		if len(sub.Script) > maxJobScriptSize {
+			addf("script is %d bytes, the maximum is %d; put larger scripts on shared storage and use script_path", len(sub.Script), maxJobScriptSize)
+		}
+	case !scriptPathPattern.MatchString(sub.ScriptPath) || strings.Contains(sub.ScriptPath, ".."):
+		addf("script_path %q must be an absolute path of letters, digits, '.', '_', '-' and '/'", sub.ScriptPath)
+	}
+	if sub.WorkingDirectory != "" && (!scriptPathPattern.MatchString(sub.WorkingDirectory) || strings.Contains(sub.WorkingDirectory, "..")) {
+		addf("working_directory %q must be an absolute path of letters, digits, '.', '_', '-' and '/'", sub.WorkingDirectory)
+	}
+	if sub.JobName != "" && !jobNamePattern.MatchString(sub.JobName) {
+		addf("job_name %q must be at most 64 letters, digits, '.', '_' and '-'", sub.JobName)
+	}
+	if sub.TimeLimit != "" {
+		if _, err := parseTimeLimit(sub.TimeLimit); err != nil {
+			addf("%v", err)
+		}
+	}
+
+	slurm := cluster.Orchestrator.Slurm
+	if sub.Partition == "" {
+		sub.Partition = slurm.DefaultPartition
+	}
+	nodes, gpusPerNode, found := partitionCapacity(cluster, sub.Partition)
+	if !found {
+		var ids []string
+		for _, p := range slurm.Partitions {
+			ids = append(ids, p.ID)
+		}
+		addf("cluster %s has no partition %q, its partitions are: %s", ShortName(cluster.Name), sub.Partition, strings.Join(ids, ", "))
+		return problems
+	}
+	// A partition without static nodes is autoscaled or scaled down to 0,
+	// and Slurm queues its jobs until nodes come up.
+	if sub.Nodes < 1 {
+		addf("nodes must be at least 1")
+	} else if nodes > 0 && int64(sub.Nodes) > nodes {
+		addf("partition %s has %d node(s), the job asks for %d", sub.Partition, nodes, sub.Nodes)
+	}
+	if sub.GPUsPerNode < 0 {
+		addf("gpus_per_node must not be negative")
+	} else if int64(sub.GPUsPerNode) > gpusPerNode {
+		addf("the nodes of partition %s have %d GPU(s), the job asks for %d per node", sub.Partition, gpusPerNode, sub.GPUsPerNode)
+	}
+	return problems
+}
+
+// sbatchOptions returns the sbatch options of sub.
+func sbatchOptions(sub JobSubmission) []string {
+	opts := []string{
+		"--parsable",
+		"--partition=" + sub.Partition,
+		"--nodes=" + strconv.Itoa(sub.Nodes),
+	}
+	if sub.GPUsPerNode > 0 {
+		opts = append(opts, "--gpus-per-node="+strconv.Itoa(sub.GPUsPerNode))
+	}
+	if sub.TimeLimit != "" {
+		opts = append(opts, "--time="+sub.TimeLimit)
+	}
+	if sub.JobName != "" {
+		opts = append(opts, "--job-name="+sub.JobName)
+	}
+	if sub.WorkingDirectory != "" {
+		opts = append(opts, "--chdir="+sub.WorkingDirectory)
+	}
+	return opts
+}
+
+// sbatchCommand returns the sbatch command that submits sub. An inline
+// script is sent on the standard input of sbatch.
+func sbatchCommand(sub JobSubmission) remote.Command {
+	cmd := remote.Command{Program: "sbatch", Args: sbatchOptions(sub)}
+	if sub.ScriptPath != "" {
+		cmd.Args = append(cmd.Args, sub.ScriptPath)
+	} else {
+		cmd.Stdin = []byte(sub.Script)
+	}
+	return cmd
+}
+
+// parseSbatchOutput returns the job ID printed by sbatch --parsable, which is
+// "<job id>[;<cluster>]" on the last line.
+func parseSbatchOutput(output string) (string, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	id, _, _ := strings.Cut(last, ";")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return "", fmt.Errorf("unexpected sbatch output %q", output)
+	}
+	return id, nil
+}
+
+func (b *sshSlurmBackend) Submit(ctx context.Context, sub JobSubmission) (string, error) {
+	output, err := b.exec(ctx, sbatchCommand(sub))
+	if err != nil {
+		return "", err
+	}
+	return parseSbatchOutput(output)
+}
+
+func (b *restSlurmBackend) Submit(ctx context.Context, sub JobSubmission) (string, error) {
+	if sub.ScriptPath != "" {
+		return "", fmt.Errorf("script_path can only be submitted over ssh")
+	}
+	job := map[string]interface{}{
+		"partition": sub.Partition,
+		"nodes":     strconv.Itoa(sub.Nodes),
+		// slurmrestd does not inherit an environment like sbatch does
+		"environment":               []string{"PATH=/usr/local/bin:/usr/bin:/bin"},
+		"current_working_directory": "/tmp",
+	}
+	if sub.WorkingDirectory != "" {
+		job["current_working_directory"] = sub.WorkingDirectory
+	}
+	if sub.GPUsPerNode > 0 {
+		job["tres_per_node"] = "gres/gpu:" + strconv.Itoa(sub.GPUsPerNode)
+	}
+	if sub.TimeLimit != "" {
+		minutes, err := parseTimeLimit(sub.TimeLimit)
+		if err != nil {
+			return "", err
+		}
+		job["time_limit"] = slurmNumber{Set: true, Number: minutes}
+	}
+	if sub.JobName != "" {
+		job["name"] = sub.JobName
+	}
+
+	var resp struct {
+		JobID slurmNumber `json:"job_id"`
+	}
+	if err := b.call(ctx, http.MethodPost, "job/submit", map[string]interface{}{
+		"script": sub.Script,
+		"job":    job,
+	}, &resp); err != nil {
+		return "", err
+	}
+	if !resp.JobID.Set {
+		return "", fmt.Errorf("slurmrestd did not return a job ID")
+	}
+	return strconv.FormatInt(resp.JobID.Number, 10), nil
+}
+
+func (h *handlers) submitJob(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	t, err := h.slurmTargetFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	sub := JobSubmission{
+		Script:           request.GetString("script", ""),
+		ScriptPath:       request.GetString("script_path", ""),
+		Partition:        request.GetString("partition", ""),
+		Nodes:            request.GetInt("nodes", 1),
+		GPUsPerNode:      request.GetInt("gpus_per_node", 0),
+		TimeLimit:        request.GetString("time_limit", ""),
+		JobName:          request.GetString("job_name", ""),
+		WorkingDirectory: request.GetString("working_directory", ""),
+	}
+
+	cluster, _, err := h.findCluster(ctx, t.projectID, t.clusterName, false)
+	if err != nil {
+		return h.toolError(err, t.projectID), nil
+	}
+	if problems := validateSubmission(cluster, &sub); len(problems) > 0 {
+		return mcp.NewToolResultError("The job is invalid:\n  - " + strings.Join(problems, "\n  - ")), nil
+	}
+	if sub.ScriptPath != "" && t.backend == SlurmBackendAuto {
+		// slurmrestd needs the script body, only sbatch reads it from a path
+		t.backend = SlurmBackendSSH
+	}
+
+	return h.runSlurm(ctx, t, "job", func(b slurmBackend) (interface{}, error) {
+		id, err := b.Submit(ctx, sub)
+		if err != nil {
+			return nil, slurmChange(err)
+		}
+		slog.InfoContext(ctx, "Submitted job", "job", id, "cluster", t.clusterName)
+		return SubmittedJob{JobID: id, Cluster: t.clusterName, Partition: sub.Partition, Nodes: sub.Nodes}, nil
+	}), nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/nadig-google/cluster-director-mcp/pkg/genericCore"
+)
+
+const (
+	Stdio          = "stdio"
+	SSE            = "sse"
+	StreamableHTTP = "streamable-http"
+
+	DefaultAddress         = "localhost:8080"
+	DefaultEndpointPath    = "/mcp"
+	DefaultShutdownTimeout = 10 * time.Second
+
+	// RequestIDHeader carries the ID of an HTTP request, given by the client
+	// or else generated, that the log records of serving it carry.
+	RequestIDHeader = "X-Request-Id"
+)
+
+// Names returns the supported transports, in the order they are documented.
+func Names() []string {
+	return []string{Stdio, SSE, StreamableHTTP}
+}
+
+// Options controls how the MCP server is exposed to clients.
+type Options struct {
+	// Transport is one of Stdio, SSE or StreamableHTTP.
+	Transport string
+	// Address is the host:port the HTTP transports listen on.
+	Address string
+	// EndpointPath is the path the streamable-http transport is mounted on.
+	// The SSE transport uses it as the base path for its /sse and /message
+	// endpoints.
+	EndpointPath string
+	// BaseURL is the externally visible URL of the server. The SSE transport
+	// advertises it to clients so they know where to POST messages. When
+	// empty it is derived from Address.
+	BaseURL string
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set.
+	TLSCertFile string
+	TLSKeyFile  string
+	// ShutdownTimeout bounds how long in-flight requests are given to finish
+	// once the server is asked to stop.
+	ShutdownTimeout time.Duration
+}
+
+func (o *Options) setDefaults() {
+	if o.Transport == "" {
+		o.Transport = Stdio
+	}
+	if o.Address == "" {
+		o.Address = DefaultAddress
+	}
+	if o.EndpointPath == "" {
+		o.EndpointPath = DefaultEndpointPath
+	}
+	if o.ShutdownTimeout <= 0 {
+		o.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if o.BaseURL == "" {
+		scheme := "http"
+		if o.tlsEnabled() {
+			scheme = "https"
+		}
+		o.BaseURL = scheme + "://" + o.Address
+	}
+}
+
+func (o *Options) tlsEnabled() bool {
+	return o.TLSCertFile != "" && o.TLSKeyFile != ""
+}
+
+// Validate reports option combinations that cannot be served.
+func (o *Options) Validate() error {
+	switch o.Transport {
+	case "", Stdio, SSE, StreamableHTTP:
+	default:
+		return fmt.Errorf("unknown transport %q, must be one of %v", o.Transport, Names())
+	}
+	if (o.TLSCertFile == "") != (o.TLSKeyFile == "") {
+		return errors.New("both a TLS certificate and a TLS key must be provided")
+	}
+	return nil
+}
+
+// Serve exposes s over the transport selected in opts and blocks until ctx is
+// cancelled or the transport fails. HTTP transports are shut down gracefully,
+// giving in-flight requests up to opts.ShutdownTimeout to complete.
+func Serve(ctx context.Context, s *server.MCPServer, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	opts.setDefaults()
+
+	if opts.Transport == Stdio {
+		return server.NewStdioServer(s).Listen(ctx, os.Stdin, os.Stdout)
+	}
+
+	ln, err := net.Listen("tcp", opts.Address)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %w", opts.Address, err)
+	}
+	return serveHTTP(ctx, s, ln, opts)
+}
+
+// httpTransport is the subset of the mcp-go HTTP servers that Serve needs.
+type httpTransport interface {
+	http.Handler
+	Shutdown(ctx context.Context) error
+}
+
+// newHTTPTransport builds the mcp-go handler for opts.Transport. srv is handed
+// to the handler so that its Shutdown also stops the listener.
+func newHTTPTransport(s *server.MCPServer, srv *http.Server, opts Options) (http.Handler, httpTransport) {
+	if opts.Transport == SSE {
+		sse := server.NewSSEServer(s,
+			server.WithHTTPServer(srv),
+			server.WithBaseURL(opts.BaseURL),
+			server.WithStaticBasePath(opts.EndpointPath),
+			server.WithKeepAlive(true),
+		)
+		return sse, sse
+	}
+
+	streamable := server.NewStreamableHTTPServer(s,
+		server.WithStreamableHTTPServer(srv),
+		server.WithEndpointPath(opts.EndpointPath),
+	)
+	mux := http.NewServeMux()
+	mux.Handle(opts.EndpointPath, streamable)
+	return mux, streamable
+}
+
+// withRequestID returns next, adding the ID of every request to the records
+// logged while serving it and to the response headers.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = genericCore.NewCorrelationID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := genericCore.WithLogAttrs(r.Context(), slog.String("request_id", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func serveHTTP(ctx context.Context, s *server.MCPServer, ln net.Listener, opts Options) error {
+	srv := &http.Server{
+		Addr:              opts.Address,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	handler, t := newHTTPTransport(s, srv, opts)
+	srv.Handler = withRequestID(handler)
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("Serving MCP", "transport", opts.Transport, "url", opts.BaseURL+opts.EndpointPath)
+		if opts.tlsEnabled() {
+			errCh <- srv.ServeTLS(ln, opts.TLSCertFile, opts.TLSKeyFile)
+		} else {
+			errCh <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down", "transport", opts.Transport)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+	defer cancel()
+	if err := t.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
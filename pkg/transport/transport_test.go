@@ -0,0 +1,102 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package transport
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestServeStreamableHTTP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	opts := Options{Transport: StreamableHTTP, Address: ln.Addr().String()}
+	opts.setDefaults()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serveHTTP(ctx, server.NewMCPServer("test", "0.0.1"), ln, opts)
+	}()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","clientInfo":{"name":"test","version":"0"}}}`
+	resp, err := http.Post(opts.BaseURL+opts.EndpointPath, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", resp.StatusCode, got)
+	}
+	if !strings.Contains(string(got), `"serverInfo"`) {
+		t.Errorf("initialize response does not contain serverInfo: %s", got)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serveHTTP returned error after shutdown: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveHTTP did not return after the context was cancelled")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		opts    Options
+		wantErr bool
+	}{
+		{Options{}, false},
+		{Options{Transport: SSE}, false},
+		{Options{Transport: "grpc"}, true},
+		{Options{Transport: StreamableHTTP, TLSCertFile: "cert.pem"}, true},
+		{Options{Transport: StreamableHTTP, TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}, false},
+	}
+	for _, tc := range tests {
+		if err := tc.opts.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("Validate(%+v) = %v, wantErr %v", tc.opts, err, tc.wantErr)
+		}
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set(RequestIDHeader, "client-id")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get(RequestIDHeader); got != "client-id" {
+		t.Errorf("%s = %q, want the ID sent by the client", RequestIDHeader, got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", nil))
+	if got := rec.Header().Get(RequestIDHeader); got == "" {
+		t.Errorf("%s is not set for a request without an ID", RequestIDHeader)
+	}
+}